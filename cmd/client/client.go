@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -8,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"google.golang.org/protobuf/encoding/protojson"
 	"jdtw.dev/links/pkg/client"
@@ -18,16 +21,26 @@ import (
 )
 
 var (
-	priv   = flag.String("priv", "", "Path to private key; can also be specified via the LINKS_PRIVATE_KEY environment variable.")
-	addr   = flag.String("addr", "", "Appliction URI; can also be specified via the LINKS_ADDR environment variable")
-	index  = flag.String("index", "", "Set the root redirect")
-	add    = flag.String("add", "", "Add a redirect")
-	link   = flag.String("link", "", "The redirect")
-	get    = flag.String("get", "", "Get a redirect")
-	rm     = flag.String("rm", "", "Remove a redirect")
-	server = flag.Int("server", -1, "If not -1, starts starts a frontent HTTP server on the given port.")
-	export = flag.String("export", "", "Write all links as a JSON Links proto to the given file, or '-' for stdout")
-	imprt  = flag.String("import", "", "Bulk create or update links from a JSON Links proto file, or '-' for stdin")
+	priv      = flag.String("priv", "", "Path to private key; can also be specified via the LINKS_PRIVATE_KEY environment variable.")
+	addr      = flag.String("addr", "", "Appliction URI; can also be specified via the LINKS_ADDR environment variable")
+	index     = flag.String("index", "", "Set the root redirect")
+	add       = flag.String("add", "", "Add a redirect")
+	link      = flag.String("link", "", "The redirect")
+	get       = flag.String("get", "", "Get a redirect")
+	rm        = flag.String("rm", "", "Remove a redirect")
+	server    = flag.Int("server", -1, "If not -1, starts starts a frontent HTTP server on the given port.")
+	export    = flag.String("export", "", "Write all links as a JSON Links proto to the given file, or '-' for stdout")
+	imprt     = flag.String("import", "", "Bulk create or update links from a JSON Links proto file, or '-' for stdin")
+	mv        = flag.String("mv", "", "Rename a redirect: --mv old new")
+	overwrite = flag.Bool("overwrite", false, "With --mv, replace the link under the new name if there is one")
+	alias     = flag.Bool("alias", false, "With --mv, keep the old name working as an alias")
+	shorten   = flag.String("shorten", "", "Create a link to the given URI under a new key and print its short URL")
+	key       = flag.String("key", "", "With --shorten, the key to create instead of a generated one; fails if taken")
+	replace   = flag.Bool("replace", false, "With --import, delete links on the server that the file does not mention")
+	dryRun    = flag.Bool("dry-run", false, "With --import, print what the import would change without changing anything")
+	binary    = flag.Bool("binary", false, "Talk to the server in binary protobuf instead of JSON; files are still JSON")
+	whoami    = flag.Bool("whoami", false, "Print who the server takes this key to be, and how far apart the clocks are")
+	readOnly  = flag.String("read-only", "", "Turn the read-only mode of the server process that handles the request 'on' or 'off', or print it with 'status'")
 )
 
 func main() {
@@ -56,7 +69,35 @@ func main() {
 	}
 
 	c := client.New(*addr, signer)
+	c.Binary = *binary
 	switch {
+	case flag.Arg(0) == "sync":
+		runSync(c, flag.Args()[1:])
+	case *whoami:
+		id, err := c.WhoAmI()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("subject:\t%s\n", id.Subject)
+		fmt.Printf("server time:\t%s\n", id.ServerTime.Local().Format(time.RFC3339))
+		fmt.Printf("clock skew:\t%v (positive if the local clock is ahead)\n", id.ClockSkew.Round(time.Millisecond))
+	case *readOnly != "":
+		switch *readOnly {
+		case "on", "off":
+			if err := c.SetReadOnly(*readOnly == "on"); err != nil {
+				log.Fatal(err)
+			}
+			log.Print("only the server process that handled this request was switched, until it restarts; " +
+				"other servers sharing its database keep their own read-only mode")
+		case "status":
+		default:
+			log.Fatalf("unknown --read-only %q; want on, off or status", *readOnly)
+		}
+		ro, err := c.ReadOnly()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("read-only:", ro)
 	case *server != -1:
 		addr := fmt.Sprint(":", *server)
 		log.Printf("listening on %q", addr)
@@ -72,6 +113,26 @@ func main() {
 		if err := c.Put(*add, *link); err != nil {
 			log.Fatal(err)
 		}
+	case *mv != "":
+		if flag.NArg() != 1 {
+			log.Fatal("usage: client --mv old new")
+		}
+		to := flag.Arg(0)
+		err := c.Rename(*mv, to, client.RenameOptions{Overwrite: *overwrite, Alias: *alias})
+		switch {
+		case errors.Is(err, client.ErrNotFound):
+			log.Fatalf("no link named %q", *mv)
+		case errors.Is(err, client.ErrExists):
+			log.Fatalf("link %q already exists; use --overwrite to replace it", to)
+		case err != nil:
+			log.Fatal(err)
+		}
+	case *shorten != "":
+		k, err := c.Create(*key, *shorten)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(strings.TrimSuffix(*addr, "/") + "/" + k)
 	case *get != "":
 		redir, err := c.Get(*get)
 		if err != nil {
@@ -79,7 +140,9 @@ func main() {
 		}
 		fmt.Println(redir)
 	case *rm != "":
-		if err := c.Delete(*rm); err != nil {
+		if err := c.Delete(*rm); errors.Is(err, client.ErrNotFound) {
+			log.Fatalf("no link named %q", *rm)
+		} else if err != nil {
 			log.Fatal(err)
 		}
 	case *export != "":
@@ -115,7 +178,17 @@ func main() {
 		if err := protojson.Unmarshal(data, lpb); err != nil {
 			log.Fatalf("failed to parse %s: %v", *imprt, err)
 		}
-		if err := c.Import(lpb); err != nil {
+		opts := client.ImportOptions{Replace: *replace}
+		if *dryRun {
+			opts.DryRun = true
+			diff, err := c.ImportWithOptions(lpb, opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+			printDiff(os.Stdout, diff)
+			return
+		}
+		if _, err := c.ImportWithOptions(lpb, opts); err != nil {
 			log.Fatal(err)
 		}
 		log.Printf("imported %d links", len(lpb.GetLinks()))
@@ -134,3 +207,23 @@ func main() {
 		}
 	}
 }
+
+// printDiff writes an import diff for review, one changed link per line,
+// followed by a summary.
+func printDiff(w io.Writer, diff *pb.LinksDiff) {
+	for _, c := range diff.GetAdded() {
+		fmt.Fprintf(w, "+ %s\t%s\n", c.GetKey(), c.GetNew().GetUri())
+	}
+	for _, c := range diff.GetUpdated() {
+		if c.GetOld().GetUri() == c.GetNew().GetUri() {
+			fmt.Fprintf(w, "~ %s\t%s (managed: %t -> %t)\n", c.GetKey(), c.GetNew().GetUri(), c.GetOld().GetManaged(), c.GetNew().GetManaged())
+			continue
+		}
+		fmt.Fprintf(w, "~ %s\t%s -> %s\n", c.GetKey(), c.GetOld().GetUri(), c.GetNew().GetUri())
+	}
+	for _, c := range diff.GetDeleted() {
+		fmt.Fprintf(w, "- %s\t%s\n", c.GetKey(), c.GetOld().GetUri())
+	}
+	fmt.Fprintf(w, "%d to add, %d to update, %d to delete, %d unchanged\n",
+		len(diff.GetAdded()), len(diff.GetUpdated()), len(diff.GetDeleted()), len(diff.GetUnchanged()))
+}
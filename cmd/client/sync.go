@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+	"jdtw.dev/links/pkg/client"
+)
+
+// syncFile is the links-as-code file read by `client sync`. It is YAML, and
+// since YAML is a superset of JSON, JSON works too:
+//
+//	links:
+//	  rfc: https://datatracker.ietf.org/doc/html/rfc{0}
+//	  go:
+//	    uri: https://go.dev
+//
+// A link is either a bare URI or a mapping with a uri field, so the output of
+// `client --export` is a valid sync file as well.
+type syncFile struct {
+	Links map[string]syncLink `yaml:"links"`
+}
+
+type syncLink string
+
+func (l *syncLink) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		return n.Decode((*string)(l))
+	}
+	var m struct {
+		URI string `yaml:"uri"`
+	}
+	if err := n.Decode(&m); err != nil {
+		return err
+	}
+	if m.URI == "" {
+		return fmt.Errorf("line %d: link has no uri", n.Line)
+	}
+	*l = syncLink(m.URI)
+	return nil
+}
+
+// parseSyncFile returns the links in a sync file as a map of names to URIs.
+func parseSyncFile(data []byte) (map[string]string, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var f syncFile
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	// An empty file would delete every managed link, which is much more
+	// likely to be a mistake than intended. Emptying the set takes an
+	// explicit "links: {}".
+	if f.Links == nil {
+		return nil, errors.New(`missing "links"`)
+	}
+	links := make(map[string]string, len(f.Links))
+	for k, l := range f.Links {
+		links[k] = string(l)
+	}
+	return links, nil
+}
+
+// runSync implements `client sync [--apply | --check] <file>`. It prints the
+// plan and, with --apply, carries it out. With --check it exits with status 2
+// if the server has drifted from the file, for use in CI.
+func runSync(c *client.Client, args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	apply := fs.Bool("apply", false, "Apply the plan instead of only printing it")
+	check := fs.Bool("check", false, "Exit with status 2 if the server differs from the file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: client sync [--apply | --check] <file>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *apply && *check {
+		log.Fatal("--apply and --check are mutually exclusive")
+	}
+
+	file := fs.Arg(0)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	desired, err := parseSyncFile(data)
+	if err != nil {
+		log.Fatalf("failed to parse %s: %v", file, err)
+	}
+
+	plan, err := c.Plan(desired)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printDiff(os.Stdout, plan)
+
+	switch {
+	case !client.HasChanges(plan):
+	case *check:
+		os.Exit(2)
+	case *apply:
+		if err := c.Apply(plan); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("applied %d changes", len(plan.GetAdded())+len(plan.GetUpdated())+len(plan.GetDeleted()))
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestParseSyncFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{{
+		name: "yaml",
+		in: `
+links:
+  rfc: https://datatracker.ietf.org/doc/html/rfc{0}
+  go:
+    uri: https://go.dev
+`,
+		want: map[string]string{
+			"rfc": "https://datatracker.ietf.org/doc/html/rfc{0}",
+			"go":  "https://go.dev",
+		},
+	}, {
+		name: "export",
+		in:   `{"links": {"go": {"uri": "https://go.dev", "managed": true}}}`,
+		want: map[string]string{"go": "https://go.dev"},
+	}, {
+		name: "explicitly empty",
+		in:   "links: {}",
+		want: map[string]string{},
+	}, {
+		name:    "empty file",
+		in:      "",
+		wantErr: true,
+	}, {
+		name:    "unknown field",
+		in:      "link:\n  go: https://go.dev\n",
+		wantErr: true,
+	}, {
+		name:    "missing uri",
+		in:      "links:\n  go:\n    url: https://go.dev\n",
+		wantErr: true,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSyncFile([]byte(tc.in))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("parseSyncFile = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSyncFile failed: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("parseSyncFile = %v, want %v", got, tc.want)
+			}
+			for k, uri := range tc.want {
+				if got[k] != uri {
+					t.Errorf("parseSyncFile[%s] = %q, want %q", k, got[k], uri)
+				}
+			}
+		})
+	}
+}
@@ -2,13 +2,19 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	"jdtw.dev/links/pkg/links"
@@ -16,68 +22,353 @@ import (
 )
 
 var (
-	ephemeral = flag.Bool("ephemeral", false, "If true, ignore SQLITE_PATH and use in-memory storage")
+	ephemeral = flag.Bool("ephemeral", false, "If true, ignore DATABASE_URL, SQLITE_PATH and LINKS_FILE and use in-memory storage")
+	readOnly  = flag.Bool("read-only", false, "If true, start refusing writes; PUT /api/readonly (client --read-only=off) lifts it at runtime")
+	logFormat = flag.String("log-format", "", "Log format, 'text' or 'json'; can also be specified via the LOG_FORMAT environment variable. Defaults to text.")
 )
 
 func main() {
 	flag.Parse()
-	log.SetPrefix("links: ")
 
+	if *logFormat == "" {
+		*logFormat = os.Getenv("LOG_FORMAT")
+	}
+	switch *logFormat {
+	case "", "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	default:
+		log.Fatalf("unknown log format %q; want 'text' or 'json'", *logFormat)
+	}
+
+	// The platform stops machines with SIGINT or SIGTERM. Either one starts
+	// a graceful shutdown; a second one kills the process outright.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if err := run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// database is a store backed by a SQL database, which can keep token
+// nonces as well as links.
+type database interface {
+	NonceVerifier(ctx context.Context, skew time.Duration) (*links.SQLNonceVerifier, error)
+}
+
+// run serves until ctx is cancelled. It returns, rather than exits, on
+// failure so that its deferred cleanup -- most importantly closing the
+// database -- always runs.
+func run(ctx context.Context) error {
 	port := 8080
 	if env := os.Getenv("PORT"); env != "" {
 		parsed, err := strconv.Atoi(env)
 		if err != nil {
-			log.Fatalf("failed to parse PORT %q", env)
+			return fmt.Errorf("failed to parse PORT %q", env)
 		}
 		port = parsed
 	}
 
 	encoded := os.Getenv("LINKS_KEYSET")
 	if encoded == "" {
-		log.Fatal("LINKS_KEYSET environment variable must be set")
+		return errors.New("LINKS_KEYSET environment variable must be set")
 	}
 	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		log.Fatalf("base64 decoding keyset failed: %v", err)
+		return fmt.Errorf("base64 decoding keyset failed: %v", err)
 	}
 	keyset, err := token.UnmarshalKeyset(decoded)
 	if err != nil {
-		log.Fatalf("token.UnmarshalKeyset failed: %v", err)
+		return fmt.Errorf("token.UnmarshalKeyset failed: %v", err)
 	}
-	log.Printf("loaded keyset:\n%s", keyset)
+	slog.Info("loaded keyset", "keyset", keyset.String())
 
-	// Storage is the SQLite database at SQLITE_PATH, unless -ephemeral asks
-	// for a throwaway in-memory store.
+	// Storage is the PostgreSQL database at DATABASE_URL, the SQLite
+	// database at SQLITE_PATH or the file at LINKS_FILE, unless -ephemeral
+	// asks for a throwaway in-memory store. db is the database, if there is
+	// one.
 	var store links.Store
-	ctx := context.Background()
-	if *ephemeral {
-		log.Printf("Running in ephemeral mode!")
+	var db database
+	databaseURL, sqlitePath, linksFile := os.Getenv("DATABASE_URL"), os.Getenv("SQLITE_PATH"), os.Getenv("LINKS_FILE")
+	set := 0
+	for _, v := range []string{databaseURL, sqlitePath, linksFile} {
+		if v != "" {
+			set++
+		}
+	}
+	switch {
+	case *ephemeral:
+		slog.Warn("running in ephemeral mode!")
 		store = links.NewMemStore()
-	} else {
-		sqlitePath := os.Getenv("SQLITE_PATH")
-		if sqlitePath == "" {
-			log.Fatal("SQLITE_PATH environment variable must be set (or pass -ephemeral)")
+	case set > 1:
+		return errors.New("set only one of DATABASE_URL, SQLITE_PATH and LINKS_FILE")
+	case databaseURL != "":
+		pgStore, err := links.NewPostgresStore(ctx, databaseURL)
+		if err != nil {
+			return fmt.Errorf("links.NewPostgresStore failed: %v", err)
 		}
+		// The URL can hold a password, so it stays out of the log.
+		slog.Info("opened PostgreSQL database")
+		store, db = pgStore, pgStore
+		defer func() {
+			if err := pgStore.Close(); err != nil {
+				slog.Error("closing PostgreSQL database failed", "error", err)
+				return
+			}
+			slog.Info("closed PostgreSQL database")
+		}()
+	case sqlitePath != "":
 		sqliteStore, err := links.NewSQLiteStore(ctx, sqlitePath)
 		if err != nil {
-			log.Fatalf("links.NewSQLiteStore failed: %v", err)
+			return fmt.Errorf("links.NewSQLiteStore failed: %v", err)
 		}
-		log.Printf("Opened SQLite database at %s", sqlitePath)
-		store = sqliteStore
-		defer sqliteStore.Close()
+		slog.Info("opened SQLite database", "path", sqlitePath)
+		store, db = sqliteStore, sqliteStore
+		// Deferred calls run after the servers below have drained, so no
+		// request can still be using the database when it closes.
+		defer func() {
+			if err := sqliteStore.Close(); err != nil {
+				slog.Error("closing SQLite database failed", "error", err)
+				return
+			}
+			slog.Info("closed SQLite database", "path", sqlitePath)
+		}()
+	case linksFile != "":
+		fileStore, err := links.NewFileStore(ctx, linksFile)
+		if err != nil {
+			return fmt.Errorf("links.NewFileStore failed: %v", err)
+		}
+		slog.Info("opened links file", "path", linksFile)
+		store = fileStore
+		defer fileStore.Close()
+	default:
+		return errors.New("DATABASE_URL, SQLITE_PATH or LINKS_FILE environment variable must be set (or pass -ephemeral)")
 	}
 
-	skew := time.Duration(0)
-	if val := os.Getenv("SKEW"); val != "" {
-		d, err := time.ParseDuration(val)
+	// Redirects are served through an LRU cache unless CACHE_SIZE is 0. The
+	// cache only sees writes made through this process, so it is only put in
+	// front of stores this process owns: other instances sharing a PostgreSQL
+	// database would be served stale links, and a links file is already
+	// served from memory, so caching it would only hide hand edits.
+	cacheSize := 1024
+	if env := os.Getenv("CACHE_SIZE"); env != "" {
+		parsed, err := strconv.Atoi(env)
 		if err != nil {
-			log.Fatalf("failed to parse %q as a time.Duration: %v", val, err)
+			return fmt.Errorf("failed to parse CACHE_SIZE %q", env)
+		}
+		cacheSize = parsed
+	}
+	cacheTTL, err := durationEnv("CACHE_TTL", time.Minute)
+	if err != nil {
+		return err
+	}
+	// Store latencies are measured beneath the cache, so that they are the
+	// backend's rather than those of cache hits.
+	served := links.NewInstrumentedStore(store)
+	switch store.(type) {
+	case *links.PostgresStore, *links.FileStore:
+	default:
+		if cacheSize > 0 {
+			slog.Info("caching links", "size", cacheSize, "ttl", cacheTTL)
+			served = links.NewCachedStore(served, cacheSize, cacheTTL)
 		}
-		skew = d
-		log.Printf("Allowing auth skew of %s", skew)
 	}
 
-	addr := fmt.Sprint(":", port)
-	log.Printf("listening on %q", addr)
-	log.Fatal(http.ListenAndServe(addr, links.NewHandler(store, keyset, skew)))
+	skew, err := durationEnv("SKEW", 0)
+	if err != nil {
+		return err
+	}
+	if skew != 0 {
+		slog.Info("allowing auth skew", "skew", skew)
+	}
+
+	// The nonces of accepted tokens, which stop them being replayed, are
+	// kept in the database so that a restart doesn't forget them, and so
+	// that every server sharing a PostgreSQL database sees them.
+	// NONCE_STORE=memory keeps them in memory instead, as -ephemeral and
+	// LINKS_FILE must.
+	opts := []links.Option{links.WithKeyset(keyset), links.WithSkew(skew)}
+	nonceStore := os.Getenv("NONCE_STORE")
+	if nonceStore == "" {
+		nonceStore = "database"
+		if db == nil {
+			nonceStore = "memory"
+		}
+	}
+	switch nonceStore {
+	case "memory":
+	// "sqlite" is what "database" was called when SQLite was the only one.
+	case "database", "sqlite":
+		if db == nil {
+			return fmt.Errorf("NONCE_STORE=%s needs a database; it can't be used with -ephemeral or LINKS_FILE", nonceStore)
+		}
+		nv, err := db.NonceVerifier(ctx, skew)
+		if err != nil {
+			return fmt.Errorf("NonceVerifier failed: %v", err)
+		}
+		opts = append(opts, links.WithNonceVerifier(nv))
+	default:
+		return fmt.Errorf("unknown NONCE_STORE %q; want 'database' or 'memory'", nonceStore)
+	}
+	slog.Info("recording token nonces", "store", nonceStore)
+
+	if *readOnly {
+		slog.Warn("starting read-only; writes will be refused")
+		opts = append(opts, links.WithReadOnly(true))
+	}
+
+	var timeouts serverTimeouts
+	if err := timeouts.fromEnv(); err != nil {
+		return err
+	}
+
+	servers := map[string]*http.Server{
+		fmt.Sprint(":", port): timeouts.server(links.New(served, opts...)),
+	}
+
+	// Metrics are served on their own admin port, if at all, so that they
+	// never share a namespace with link keys and can be kept off the public
+	// listener entirely. METRICS_TOKEN additionally requires scrapers to
+	// present it as a bearer token.
+	if metricsAddr := os.Getenv("METRICS_ADDR"); metricsAddr != "" {
+		var metrics http.Handler = links.MetricsHandler(store)
+		if metricsToken := os.Getenv("METRICS_TOKEN"); metricsToken != "" {
+			metrics = requireBearer(metricsToken, metrics)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		servers[metricsAddr] = timeouts.server(mux)
+	}
+
+	// If any server fails, take the others down with it rather than keep
+	// running half a service.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, len(servers))
+	for addr, srv := range servers {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			cancel()
+			errc <- err
+			continue
+		}
+		slog.Info("listening", "addr", addr)
+		go func() { errc <- serve(ctx, srv, ln, timeouts.shutdown) }()
+	}
+	var errs []error
+	for range servers {
+		if err := <-errc; err != nil {
+			cancel()
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serverTimeouts bounds how long a client may take over each part of a
+// request, so that slow or idle connections can't pile up, and how long a
+// graceful shutdown may wait for in-flight requests.
+type serverTimeouts struct {
+	readHeader time.Duration
+	read       time.Duration
+	write      time.Duration
+	idle       time.Duration
+	shutdown   time.Duration
+}
+
+func (t *serverTimeouts) fromEnv() error {
+	for _, d := range []struct {
+		env string
+		def time.Duration
+		dst *time.Duration
+	}{
+		{"READ_HEADER_TIMEOUT", 5 * time.Second, &t.readHeader},
+		{"READ_TIMEOUT", 30 * time.Second, &t.read},
+		{"WRITE_TIMEOUT", 30 * time.Second, &t.write},
+		{"IDLE_TIMEOUT", 2 * time.Minute, &t.idle},
+		// Fly sends SIGKILL kill_timeout (5s) after the kill signal, so
+		// draining has to finish well within that.
+		{"SHUTDOWN_TIMEOUT", 4 * time.Second, &t.shutdown},
+	} {
+		v, err := durationEnv(d.env, d.def)
+		if err != nil {
+			return err
+		}
+		*d.dst = v
+	}
+	return nil
+}
+
+// maxHeaderBytes leaves plenty of room for a signed token while being far
+// smaller than http.DefaultMaxHeaderBytes.
+const maxHeaderBytes = 64 << 10
+
+func (t *serverTimeouts) server(h http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: t.readHeader,
+		ReadTimeout:       t.read,
+		WriteTimeout:      t.write,
+		IdleTimeout:       t.idle,
+		MaxHeaderBytes:    maxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serve runs srv on ln until ctx is cancelled, then stops accepting
+// connections and waits up to drain for in-flight requests to finish. It
+// returns nil after a clean shutdown.
+func serve(ctx context.Context, srv *http.Server, ln net.Listener, drain time.Duration) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "addr", ln.Addr().String(), "drain", drain)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("draining %s failed: %w", ln.Addr(), err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// durationEnv parses the environment variable env as a time.Duration,
+// returning def if it is unset.
+func durationEnv(env string, def time.Duration) (time.Duration, error) {
+	val := os.Getenv(env)
+	if val == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s=%q as a time.Duration: %v", env, val, err)
+	}
+	return d, nil
+}
+
+// requireBearer rejects requests that don't carry token as a bearer token.
+func requireBearer(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
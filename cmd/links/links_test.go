@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"jdtw.dev/links/pkg/links"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// TestGracefulShutdown sends the process SIGTERM while a request that writes
+// to the database is in flight, and checks that the request completes, that
+// the server then stops, and that the database is checkpointed and closed
+// with the write intact -- the same sequence run follows.
+func TestGracefulShutdown(t *testing.T) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	dbPath := filepath.Join(t.TempDir(), "links.db")
+	store, err := links.NewSQLiteStore(context.Background(), dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// Hold the request open until shutdown has begun, then write.
+		<-ctx.Done()
+		time.Sleep(100 * time.Millisecond)
+		if _, err := store.Put(r.Context(), "inflight", &pb.Link{Uri: "https://example.com"}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	timeouts := serverTimeouts{read: time.Second, write: 5 * time.Second}
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, timeouts.server(handler), ln, 5*time.Second) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{string(body), err}
+	}()
+
+	<-started
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("sending SIGTERM failed: %v", err)
+	}
+
+	if res := <-results; res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want it to complete", res.body, res.err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve returned %v, want nil after a clean shutdown", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve did not return after SIGTERM")
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// The checkpoint on close folds the WAL back into the database file.
+	if fi, err := os.Stat(dbPath + "-wal"); err == nil && fi.Size() != 0 {
+		t.Errorf("WAL is %d bytes after close, want it checkpointed", fi.Size())
+	}
+	reopened, err := links.NewSQLiteStore(context.Background(), dbPath)
+	if err != nil {
+		t.Fatalf("reopening failed: %v", err)
+	}
+	defer reopened.Close()
+	if le, err := reopened.Get(context.Background(), "inflight"); err != nil || le == nil {
+		t.Errorf("Get(inflight) after restart = %v, %v; want the in-flight write", le, err)
+	}
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, &http.Server{Handler: handler}, ln, 50*time.Millisecond) }()
+	go http.Get("http://" + ln.Addr().String() + "/stuck")
+
+	<-started
+	cancel()
+	if err := <-served; err == nil {
+		t.Error("serve returned nil with a request stuck past the drain timeout, want an error")
+	}
+}
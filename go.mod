@@ -3,9 +3,12 @@ module jdtw.dev/links
 go 1.25.0
 
 require (
+	connectrpc.com/connect v1.19.1
 	github.com/go-chi/chi/v5 v5.3.1
+	github.com/lib/pq v1.9.0
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	google.golang.org/protobuf v1.36.10
+	gopkg.in/yaml.v3 v3.0.1
 	jdtw.dev/token v0.1.6
 	modernc.org/sqlite v1.55.0
 )
@@ -2,10 +2,14 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
 	"time"
@@ -19,17 +23,48 @@ import (
 const (
 	linksAPI      = "/api/links"
 	tokenLifetime = time.Second * 30
+
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
 )
 
 // ErrNotFound is a sential error for HTTP status code 404.
 var ErrNotFound = errors.New("not found")
 
+// ErrPreconditionFailed is a sentinel error for HTTP status code 412: a
+// conditional write found the link changed since it was read, or present
+// when it was meant to be created.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrExists is a sentinel error for HTTP status code 409: the key asked for
+// is already taken.
+var ErrExists = errors.New("already exists")
+
+// ErrReadOnly is a sentinel error for the HTTP 503 a write gets while the
+// server is read-only.
+var ErrReadOnly = errors.New("read-only")
+
 // Client is a client for the links REST API.
+//
+// Every method has a Context variant that takes a context and returns the
+// links as protos; the plain methods call those with context.Background()
+// and keep their original, simpler signatures.
 type Client struct {
 	Host string
 	// If the key is not nil, the client sends unauthenticated requests.
 	Signer *token.SigningKey
+	// Client sends the requests. Give it a custom Transport to control how.
 	Client *http.Client
+	// Binary sends and asks for binary protobuf bodies instead of JSON,
+	// which are smaller and faster to encode for large imports and
+	// exports.
+	Binary bool
+	// Timeout, if not zero, bounds each attempt at a request, on top of
+	// any deadline of the request's context.
+	Timeout time.Duration
+	// Retry decides which failed requests are tried again. The zero value
+	// never retries.
+	Retry RetryPolicy
 }
 
 // New creates a client with a default HTTP client. If pkcs8 is nil,
@@ -42,51 +77,134 @@ func New(host string, signer *token.SigningKey) *Client {
 	}
 }
 
-func (c *Client) do(method string, path string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, c.Host+path, body)
+// Options configure a client created by NewWithOptions.
+type Options struct {
+	// Transport sends requests; nil means http.DefaultTransport.
+	Transport http.RoundTripper
+	// Binary, Timeout and Retry set the Client fields of the same name.
+	Binary  bool
+	Timeout time.Duration
+	Retry   RetryPolicy
+}
+
+// NewWithOptions creates a client as configured by opts.
+func NewWithOptions(host string, signer *token.SigningKey, opts Options) *Client {
+	c := New(host, signer)
+	c.Client.Transport = opts.Transport
+	c.Binary = opts.Binary
+	c.Timeout = opts.Timeout
+	c.Retry = opts.Retry
+	return c
+}
+
+// do sends a request, signing each attempt afresh since the server rejects
+// reused tokens, and retries it as c.Retry allows. A non-2xx response is
+// returned as an *APIError.
+func (c *Client) do(ctx context.Context, method string, path string, body []byte, header http.Header) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(ctx, method, path, body, header)
+		if err == nil {
+			return resp, nil
+		}
+		if !c.Retry.retry(ctx, method, header, attempt, err) {
+			return nil, err
+		}
+		if err := c.Retry.wait(ctx, attempt); err != nil {
+			return nil, err
+		}
+		path = retryPath(method, path)
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method string, path string, body []byte, header http.Header) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.Host+path, r)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	ct := contentTypeJSON
+	if c.Binary {
+		ct = contentTypeProtobuf
+	}
+	req.Header.Set("Accept", ct)
+	if body != nil {
+		req.Header.Set("Content-Type", ct)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
 	if c.Signer != nil {
 		if _, err := c.Signer.AuthorizeRequest(req, tokenLifetime); err != nil {
+			cancel()
 			return nil, err
 		}
 	}
 	resp, err := c.Client.Do(req)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 	if err := ok(resp); err != nil {
 		resp.Body.Close()
+		cancel()
 		return nil, err
 	}
+	// The timeout covers reading the body too, so it ends when the caller
+	// closes it.
+	resp.Body = &cancelOnClose{resp.Body, cancel}
 	return resp, nil
 }
 
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 func (c *Client) List() (map[string]string, error) {
-	resp, err := c.do("GET", linksAPI, nil)
+	links, err := c.ListContext(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	lpb := &pb.Links{}
-	if err := unmarshalBody(resp, lpb); err != nil {
-		return nil, err
-	}
-
-	l := make(map[string]string)
-	for k, v := range lpb.GetLinks() {
+	l := make(map[string]string, len(links))
+	for k, v := range links {
 		l[k] = v.GetUri()
 	}
 	return l, nil
 }
 
+// ListContext returns every link by key.
+func (c *Client) ListContext(ctx context.Context) (map[string]*pb.Link, error) {
+	lpb, err := c.ExportContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lpb.GetLinks(), nil
+}
+
 // Export returns every link as a Links proto, preserving the exact shape the
 // server stores. Unlike List, which flattens to a map of strings for display,
 // the result round-trips through Import.
 func (c *Client) Export() (*pb.Links, error) {
-	resp, err := c.do("GET", linksAPI, nil)
+	return c.ExportContext(context.Background())
+}
+
+// ExportContext is Export with a context.
+func (c *Client) ExportContext(ctx context.Context) (*pb.Links, error) {
+	resp, err := c.do(ctx, "GET", linksAPI, nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -102,38 +220,182 @@ func (c *Client) Export() (*pb.Links, error) {
 // Import bulk-creates or updates every link in lpb. Links already on the
 // server that lpb does not mention are left alone.
 func (c *Client) Import(lpb *pb.Links) error {
-	body, err := marshal(lpb)
+	_, err := c.ImportContext(context.Background(), lpb, ImportOptions{})
+	return err
+}
+
+// ImportOptions modify how ImportWithOptions applies a Links proto.
+type ImportOptions struct {
+	// Replace deletes every link on the server that the import does not
+	// mention, so that the server ends up holding exactly the import.
+	Replace bool
+	// DryRun computes what the import would do without changing anything.
+	DryRun bool
+}
+
+// ImportWithOptions imports lpb as configured by opts. For a dry run it
+// returns the diff the import would apply; otherwise the diff is nil.
+func (c *Client) ImportWithOptions(lpb *pb.Links, opts ImportOptions) (*pb.LinksDiff, error) {
+	return c.ImportContext(context.Background(), lpb, opts)
+}
+
+// ImportContext is ImportWithOptions with a context.
+func (c *Client) ImportContext(ctx context.Context, lpb *pb.Links, opts ImportOptions) (*pb.LinksDiff, error) {
+	body, err := c.marshal(lpb)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	q := url.Values{}
+	if opts.Replace {
+		q.Set("mode", "replace")
+	}
+	if opts.DryRun {
+		q.Set("dry_run", "true")
 	}
-	resp, err := c.do("POST", linksAPI, body)
+	api := linksAPI
+	if len(q) > 0 {
+		api += "?" + q.Encode()
+	}
+	resp, err := c.do(ctx, "POST", api, body, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	resp.Body.Close()
-	return nil
+	defer resp.Body.Close()
+	if !opts.DryRun {
+		return nil, nil
+	}
+	diff := &pb.LinksDiff{}
+	if err := unmarshalBody(resp, diff); err != nil {
+		return nil, err
+	}
+	return diff, nil
 }
 
 func (c *Client) Get(link string) (string, error) {
-	resp, err := c.do("GET", api(link), nil)
+	uri, _, err := c.GetWithETag(link)
+	return uri, err
+}
+
+// GetWithETag returns the link's URI along with its ETag, which identifies
+// the revision read and can be passed to PutWithOptions as IfMatch.
+func (c *Client) GetWithETag(link string) (uri string, etag string, err error) {
+	lpb, etag, err := c.GetContext(context.Background(), link)
+	return lpb.GetUri(), etag, err
+}
+
+// GetContext returns the link along with its ETag, which identifies the
+// revision read and can be passed to PutContext as IfMatch.
+func (c *Client) GetContext(ctx context.Context, link string) (*pb.Link, string, error) {
+	resp, err := c.do(ctx, "GET", api(link), nil, nil)
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 	lpb := &pb.Link{}
 	if err := unmarshalBody(resp, lpb); err != nil {
-		return "", err
+		return nil, "", err
 	}
-	return lpb.GetUri(), nil
+	return lpb, resp.Header.Get("ETag"), nil
 }
 
 func (c *Client) Put(link string, uri string) error {
-	lpb := &pb.Link{Uri: uri}
-	body, err := marshal(lpb)
+	_, err := c.PutWithOptions(link, uri, PutOptions{})
+	return err
+}
+
+// PutOptions make a Put conditional on the link's current state. A Put
+// whose condition doesn't hold fails with ErrPreconditionFailed.
+type PutOptions struct {
+	// CreateOnly only writes the link if it doesn't exist yet.
+	CreateOnly bool
+	// IfMatch, if set, only writes the link if its current ETag is IfMatch,
+	// so that an edit can't clobber a change made since the link was read.
+	IfMatch string
+}
+
+// PutWithOptions writes the link as configured by opts and returns the ETag
+// of the written revision.
+func (c *Client) PutWithOptions(link string, uri string, opts PutOptions) (string, error) {
+	return c.PutContext(context.Background(), link, &pb.Link{Uri: uri}, opts)
+}
+
+// PutContext writes lpb under link as configured by opts and returns the
+// ETag of the written revision.
+func (c *Client) PutContext(ctx context.Context, link string, lpb *pb.Link, opts PutOptions) (string, error) {
+	body, err := c.marshal(lpb)
 	if err != nil {
-		return err
+		return "", err
+	}
+	header := http.Header{}
+	if opts.CreateOnly {
+		header.Set("If-None-Match", "*")
+	}
+	if opts.IfMatch != "" {
+		header.Set("If-Match", opts.IfMatch)
+	}
+	resp, err := c.do(ctx, "PUT", api(link), body, header)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// Create stores uri under key, failing with ErrExists if key is taken,
+// and returns the key as the server normalized it. If key is empty, the
+// server generates a short, unused one.
+func (c *Client) Create(key string, uri string) (string, error) {
+	return c.CreateContext(context.Background(), key, &pb.Link{Uri: uri})
+}
+
+// CreateContext is Create for a full link, with a context.
+func (c *Client) CreateContext(ctx context.Context, key string, lpb *pb.Link) (string, error) {
+	body, err := c.marshal(&pb.CreateLinkRequest{
+		Link: lpb,
+		Key:  strings.TrimSpace(key),
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do(ctx, "POST", linksAPI+"/new", body, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	cpb := &pb.CreateLinkResponse{}
+	if err := unmarshalBody(resp, cpb); err != nil {
+		return "", err
+	}
+	return cpb.GetKey(), nil
+}
+
+// RenameOptions modify how Rename treats the old and new keys.
+type RenameOptions struct {
+	// Overwrite replaces a link already stored under the new key instead
+	// of failing with ErrExists.
+	Overwrite bool
+	// Alias leaves a copy of the link under the old key, so links to it
+	// keep working.
+	Alias bool
+}
+
+// Rename moves a link to a new key in one atomic step. It fails with
+// ErrNotFound if there is no such link, and with ErrExists if the new key is
+// taken and opts doesn't allow overwriting it.
+func (c *Client) Rename(from string, to string, opts RenameOptions) error {
+	return c.RenameContext(context.Background(), from, to, opts)
+}
+
+// RenameContext is Rename with a context.
+func (c *Client) RenameContext(ctx context.Context, from string, to string, opts RenameOptions) error {
+	q := url.Values{"to": {strings.TrimSpace(to)}}
+	if opts.Overwrite {
+		q.Set("overwrite", "true")
+	}
+	if opts.Alias {
+		q.Set("alias", "true")
 	}
-	resp, err := c.do("PUT", api(link), body)
+	resp, err := c.do(ctx, "POST", api(from)+"/rename?"+q.Encode(), nil, nil)
 	if err != nil {
 		return err
 	}
@@ -142,7 +404,12 @@ func (c *Client) Put(link string, uri string) error {
 }
 
 func (c *Client) Delete(link string) error {
-	resp, err := c.do("DELETE", api(link), nil)
+	return c.DeleteContext(context.Background(), link)
+}
+
+// DeleteContext is Delete with a context.
+func (c *Client) DeleteContext(ctx context.Context, link string) error {
+	resp, err := c.do(ctx, "DELETE", api(link), nil, nil)
 	if err != nil {
 		return err
 	}
@@ -154,30 +421,113 @@ func api(link string) string {
 	return path.Join(linksAPI, strings.TrimSpace(link))
 }
 
-func marshal(m proto.Message) (io.Reader, error) {
-	b, err := protojson.Marshal(m)
-	if err != nil {
-		return nil, err
+func (c *Client) marshal(m proto.Message) ([]byte, error) {
+	if c.Binary {
+		return proto.Marshal(m)
 	}
-	return bytes.NewReader(b), nil
+	return protojson.Marshal(m)
 }
 
+// unmarshalBody decodes resp's body in whichever format the server chose
+// to send it.
 func unmarshalBody(resp *http.Response, m proto.Message) error {
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt == contentTypeProtobuf {
+		return proto.Unmarshal(b, m)
+	}
 	return protojson.Unmarshal(b, m)
 }
 
+// APIError is an error response from the server. Use errors.As to inspect
+// it; errors.Is also matches it against ErrNotFound, ErrExists,
+// ErrPreconditionFailed and ErrReadOnly.
+type APIError struct {
+	// StatusCode is the HTTP status of the response.
+	StatusCode int `json:"-"`
+	// Code names the kind of error, e.g. "invalid_argument" or "not_found".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RequestID identifies the request in the server's logs.
+	RequestID string `json:"request_id"`
+	// Problems lists what was wrong with each rejected link of a bulk
+	// request.
+	Problems []Problem `json:"problems"`
+	// ClockSkew estimates how far the local clock is ahead of the server's
+	// (behind, if negative), from the response's Date header. A skewed
+	// clock makes the server reject tokens as expired or not yet valid, so
+	// Error mentions it for unauthenticated requests.
+	ClockSkew time.Duration `json:"-"`
+
+	method string
+	path   string
+}
+
+// Problem is something wrong with one link in a bulk request.
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s failed: %d %s", e.method, e.path, e.StatusCode, e.Message)
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request %s)", e.RequestID)
+	}
+	if e.StatusCode == http.StatusUnauthorized {
+		if skew := describeSkew(e.ClockSkew); skew != "" {
+			fmt.Fprintf(&b, "; %s, which can make the server reject tokens", skew)
+		}
+	}
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  %q: %s", p.Key, p.Message)
+	}
+	return b.String()
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrExists
+	case http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case http.StatusServiceUnavailable:
+		// Other 503s, say from a proxy, are unrelated.
+		if e.Code == "read_only" {
+			return ErrReadOnly
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
 func ok(resp *http.Response) error {
 	switch resp.StatusCode {
 	case http.StatusOK, http.StatusNoContent, http.StatusCreated:
 		return nil
-	case http.StatusNotFound:
-		return fmt.Errorf("%w: %s %s", ErrNotFound, resp.Request.Method, resp.Request.RequestURI)
-	default:
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("%s %s failed: %s %s", resp.Request.Method, resp.Request.URL, resp.Status, body)
 	}
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		method:     resp.Request.Method,
+		path:       resp.Request.URL.Path,
+	}
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		e.ClockSkew = time.Since(date)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, e); err != nil || e.Code == "" {
+		// Not from the API itself; perhaps a proxy in front of it.
+		e.Code, e.Problems = "", nil
+		e.Message = strings.TrimSpace(string(body))
+		if e.Message == "" {
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	return e
 }
@@ -2,11 +2,15 @@ package client
 
 import (
 	"errors"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"jdtw.dev/links/pkg/links"
 	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
 )
 
 func TestClient(t *testing.T) {
@@ -57,6 +61,9 @@ func TestClient(t *testing.T) {
 		if _, err := c.Get("foo"); !errors.Is(err, ErrNotFound) {
 			t.Fatal("expected link foo to be deleted")
 		}
+		if err := c.Delete("foo"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("client.Delete(foo) of a deleted link returned %v; want err %v", err, ErrNotFound)
+		}
 	}
 	// Test that Put strips whitespace.
 	if err := c.Put(" whitespace ", "http://bar"); err != nil {
@@ -73,3 +80,272 @@ func TestClient(t *testing.T) {
 	}
 
 }
+
+func TestImportWithOptions(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	store := links.NewMemStore()
+	s := httptest.NewServer(links.NewHandler(store, ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+
+	if err := c.Put("stale", "http://stale"); err != nil {
+		t.Fatalf("client.Put(stale) failed: %v", err)
+	}
+	lpb := &pb.Links{Links: map[string]*pb.Link{"foo": {Uri: "http://foo"}}}
+
+	diff, err := c.ImportWithOptions(lpb, ImportOptions{Replace: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(diff.GetAdded()) != 1 || len(diff.GetDeleted()) != 1 {
+		t.Errorf("dry run diff = %v, want foo added and stale deleted", diff)
+	}
+	if _, err := c.Get("stale"); err != nil {
+		t.Errorf("client.Get(stale) after dry run failed: %v", err)
+	}
+
+	if _, err := c.ImportWithOptions(lpb, ImportOptions{Replace: true}); err != nil {
+		t.Fatalf("replace failed: %v", err)
+	}
+	if _, err := c.Get("stale"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("client.Get(stale) after replace returned %v; want err %v", err, ErrNotFound)
+	}
+}
+
+func TestPutWithOptions(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+
+	if _, err := c.PutWithOptions("foo", "http://v1", PutOptions{CreateOnly: true}); err != nil {
+		t.Fatalf("create-only Put failed: %v", err)
+	}
+	if _, err := c.PutWithOptions("foo", "http://v1", PutOptions{CreateOnly: true}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("second create-only Put returned %v; want err %v", err, ErrPreconditionFailed)
+	}
+
+	// Two editors read the same revision; only the first write wins.
+	_, etag, err := c.GetWithETag("foo")
+	if err != nil {
+		t.Fatalf("GetWithETag failed: %v", err)
+	}
+	if _, err := c.PutWithOptions("foo", "http://first", PutOptions{IfMatch: etag}); err != nil {
+		t.Fatalf("conditional Put failed: %v", err)
+	}
+	if _, err := c.PutWithOptions("foo", "http://second", PutOptions{IfMatch: etag}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("conditional Put with a stale ETag returned %v; want err %v", err, ErrPreconditionFailed)
+	}
+	if got, err := c.Get("foo"); err != nil || got != "http://first" {
+		t.Errorf("client.Get(foo) = %q, %v; want http://first", got, err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+
+	key, err := c.Create("", "http://generated")
+	if err != nil {
+		t.Fatalf("client.Create with no key failed: %v", err)
+	}
+	if got, err := c.Get(key); err != nil || got != "http://generated" {
+		t.Errorf("client.Get(%s) = %q, %v; want http://generated", key, got, err)
+	}
+
+	if key, err := c.Create("my-key", "http://mine"); err != nil || key != "mykey" {
+		t.Fatalf("client.Create(my-key) = %q, %v; want mykey", key, err)
+	}
+	if _, err := c.Create("mykey", "http://other"); !errors.Is(err, ErrExists) {
+		t.Errorf("client.Create of a taken key returned %v; want err %v", err, ErrExists)
+	}
+}
+
+func TestRename(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+
+	for k, uri := range map[string]string{"old": "http://old", "other": "http://other"} {
+		if err := c.Put(k, uri); err != nil {
+			t.Fatalf("client.Put(%s) failed: %v", k, err)
+		}
+	}
+	if err := c.Rename("missing", "new", RenameOptions{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("client.Rename(missing) returned %v; want err %v", err, ErrNotFound)
+	}
+	if err := c.Rename("old", "other", RenameOptions{}); !errors.Is(err, ErrExists) {
+		t.Errorf("client.Rename onto a taken key returned %v; want err %v", err, ErrExists)
+	}
+	if err := c.Rename("old", "new", RenameOptions{Alias: true}); err != nil {
+		t.Fatalf("client.Rename(old, new) failed: %v", err)
+	}
+	for _, k := range []string{"old", "new"} {
+		if got, err := c.Get(k); err != nil || got != "http://old" {
+			t.Errorf("client.Get(%s) after aliased rename = %q, %v; want http://old", k, got, err)
+		}
+	}
+}
+
+func TestBinary(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+	c.Binary = true
+
+	if err := c.Put("foo", "http://foo"); err != nil {
+		t.Fatalf("binary client.Put(foo) failed: %v", err)
+	}
+	if got, err := c.Get("foo"); err != nil || got != "http://foo" {
+		t.Errorf("binary client.Get(foo) = %q, %v; want http://foo", got, err)
+	}
+	lpb := &pb.Links{Links: map[string]*pb.Link{"bar": {Uri: "http://bar"}}}
+	if err := c.Import(lpb); err != nil {
+		t.Fatalf("binary client.Import failed: %v", err)
+	}
+	got, err := c.Export()
+	if err != nil {
+		t.Fatalf("binary client.Export failed: %v", err)
+	}
+	if len(got.GetLinks()) != 2 || got.GetLinks()["bar"].GetUri() != "http://bar" {
+		t.Errorf("binary client.Export = %v, want foo and bar", got)
+	}
+	if key, err := c.Create("", "http://generated"); err != nil || key == "" {
+		t.Errorf("binary client.Create = %q, %v; want a generated key", key, err)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+
+	_, err := c.Get("missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("client.Get(missing) returned %v; want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "not_found" || apiErr.RequestID == "" {
+		t.Errorf("client.Get(missing) error = %+v; want a 404 not_found with a request ID", apiErr)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("client.Get(missing) error %v doesn't match %v", err, ErrNotFound)
+	}
+
+	err = c.Import(&pb.Links{Links: map[string]*pb.Link{
+		"good": {Uri: "http://good"},
+		"bad":  {Uri: "no-scheme"},
+		"qr":   {Uri: "http://qr"},
+	}})
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("client.Import of bad links returned %v; want an *APIError", err)
+	}
+	if apiErr.Code != "invalid_argument" || len(apiErr.Problems) != 2 {
+		t.Fatalf("client.Import error = %+v; want invalid_argument with 2 problems", apiErr)
+	}
+	if apiErr.Problems[0].Key != "bad" || apiErr.Problems[1].Key != "qr" {
+		t.Errorf("client.Import problems = %+v; want bad and qr", apiErr.Problems)
+	}
+}
+
+// An error from something other than the API, such as a proxy, still comes
+// back as an *APIError carrying the body as its message.
+func TestAPIErrorFromNonAPIResponse(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+	}))
+	t.Cleanup(s.Close)
+	c := New(s.URL, nil)
+
+	_, err := c.List()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("client.List returned %v; want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "" || apiErr.Message != "upstream unavailable" {
+		t.Errorf("client.List error = %+v; want a 502 with the body as its message", apiErr)
+	}
+}
+
+func TestWhoAmI(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "alice")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+
+	id, err := New(s.URL, signer).WhoAmI()
+	if err != nil {
+		t.Fatalf("client.WhoAmI failed: %v", err)
+	}
+	if id.Subject != "alice" {
+		t.Errorf("client.WhoAmI subject = %q, want alice", id.Subject)
+	}
+	if id.ClockSkew.Abs() > time.Second {
+		t.Errorf("client.WhoAmI clock skew = %v, want about 0 talking to a local server", id.ClockSkew)
+	}
+
+	_, evil := tokentest.GenerateKey(t, "evil")
+	if _, err := New(s.URL, evil).WhoAmI(); err == nil {
+		t.Error("client.WhoAmI with an untrusted key succeeded")
+	}
+}
+
+// A server whose clock is far from ours most likely rejected the token
+// because of it, and the error should say so.
+func TestUnauthorizedReportsClockSkew(t *testing.T) {
+	serverAhead := 5 * time.Minute
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(serverAhead).UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthenticated","message":"unauthorized: token expired"}`))
+	}))
+	t.Cleanup(s.Close)
+
+	_, err := New(s.URL, nil).List()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("client.List returned %v; want an *APIError", err)
+	}
+	if d := apiErr.ClockSkew + serverAhead; d.Abs() > 2*time.Second {
+		t.Errorf("ClockSkew = %v, want about %v", apiErr.ClockSkew, -serverAhead)
+	}
+	if !strings.Contains(err.Error(), "behind the server's") {
+		t.Errorf("error %q doesn't mention the clock skew", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	ft := &flakyTransport{}
+	c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+
+	if err := c.SetReadOnly(true); err != nil {
+		t.Fatalf("client.SetReadOnly(true) failed: %v", err)
+	}
+	if ro, err := c.ReadOnly(); err != nil || !ro {
+		t.Errorf("client.ReadOnly() = %v, %v; want true", ro, err)
+	}
+
+	// Retrying a refused write can't help, so it isn't.
+	ft.calls.Store(0)
+	if err := c.Put("foo", "http://foo"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("client.Put while read-only returned %v, want %v", err, ErrReadOnly)
+	}
+	if got := ft.calls.Load(); got != 1 {
+		t.Errorf("client.Put while read-only made %d attempts, want 1", got)
+	}
+
+	if err := c.SetReadOnly(false); err != nil {
+		t.Fatalf("client.SetReadOnly(false) failed: %v", err)
+	}
+	if err := c.Put("foo", "http://foo"); err != nil {
+		t.Errorf("client.Put after read-only mode was lifted failed: %v", err)
+	}
+}
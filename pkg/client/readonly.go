@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+// ReadOnly reports whether the server is refusing writes.
+func (c *Client) ReadOnly() (bool, error) {
+	return c.ReadOnlyContext(context.Background())
+}
+
+// ReadOnlyContext is ReadOnly with a context.
+func (c *Client) ReadOnlyContext(ctx context.Context) (bool, error) {
+	resp, err := c.do(ctx, "GET", "/api/readonly", nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	status := &pb.ReadOnlyStatus{}
+	if err := unmarshalBody(resp, status); err != nil {
+		return false, err
+	}
+	return status.GetReadOnly(), nil
+}
+
+// SetReadOnly turns the server's read-only mode on or off. While it is on,
+// writes fail with ErrReadOnly. Only the server process that handles the
+// request is switched, and only until it restarts.
+func (c *Client) SetReadOnly(readOnly bool) error {
+	return c.SetReadOnlyContext(context.Background(), readOnly)
+}
+
+// SetReadOnlyContext is SetReadOnly with a context.
+func (c *Client) SetReadOnlyContext(ctx context.Context, readOnly bool) error {
+	body, err := c.marshal(&pb.ReadOnlyStatus{ReadOnly: readOnly})
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, "PUT", "/api/readonly", body, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RetryPolicy configures how a Client retries failed requests. Only
+// requests that are safe to repeat are retried: GET, unconditional PUT, and
+// DELETE, which is retried with missing_ok=true in case an earlier attempt
+// deleted the link and only its response was lost. A POST, such as Create
+// with a generated key, could take effect twice, and a conditional PUT
+// would fail its own precondition if an earlier attempt was applied, so
+// those are tried once.
+//
+// A request is retried after a network error or a 5xx response, except a
+// write refused because the server is read-only. Anything else, such as a
+// 404 or a rejected link, would fail the same way again.
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is tried in all. Zero and one
+	// both mean no retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It doubles for
+	// each retry after that, up to MaxBackoff. Zero means
+	// DefaultInitialBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Zero means
+	// DefaultMaxBackoff.
+	MaxBackoff time.Duration
+}
+
+// The backoffs used when a RetryPolicy leaves them unset.
+const (
+	DefaultInitialBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff     = 5 * time.Second
+)
+
+// retry reports whether attempt number attempt at a request with the given
+// method and header, which failed with err, should be followed by another.
+func (p RetryPolicy) retry(ctx context.Context, method string, header http.Header, attempt int, err error) bool {
+	if attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	switch method {
+	case "GET", "PUT", "DELETE":
+	default:
+		return false
+	}
+	if header.Get("If-Match") != "" || header.Get("If-None-Match") != "" {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		// A read-only server refuses writes until someone lifts it, which
+		// retrying won't wait out.
+		return apiErr.StatusCode >= http.StatusInternalServerError && !errors.Is(err, ErrReadOnly)
+	}
+	// Anything else failed on the way to or from the server.
+	return true
+}
+
+// wait sleeps before the retry following attempt number attempt, or until
+// ctx is done.
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(p.backoff(attempt))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff is the wait after attempt number attempt: exponential, with
+// jitter so that many clients failing at once don't retry in lockstep.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d, limit := p.InitialBackoff, p.MaxBackoff
+	if d <= 0 {
+		d = DefaultInitialBackoff
+	}
+	if limit <= 0 {
+		limit = DefaultMaxBackoff
+	}
+	for i := 1; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	d = min(d, limit)
+	// Somewhere between half and all of d.
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryPath is the path to send a retry of a request for path to. A DELETE
+// whose first attempt was applied would otherwise find the link gone and
+// fail with a 404, so retries accept a missing link.
+func retryPath(method, path string) string {
+	if method != "DELETE" {
+		return path
+	}
+	p, query, _ := strings.Cut(path, "?")
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return path
+	}
+	q.Set("missing_ok", "true")
+	return p + "?" + q.Encode()
+}
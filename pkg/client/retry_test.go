@@ -0,0 +1,193 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"jdtw.dev/links/pkg/links"
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+// flakyTransport passes requests on to the server, but replaces the first
+// failures responses with a 503, as if a load balancer in front of the
+// server had failed after the server handled them.
+type flakyTransport struct {
+	failures int32
+	calls    atomic.Int32
+}
+
+func (t *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if t.calls.Add(1) > t.failures {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Status:     "503 Service Unavailable",
+		Body:       io.NopCloser(strings.NewReader("try again")),
+		Request:    req,
+	}, nil
+}
+
+func TestRetries(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	ctx := context.Background()
+
+	t.Run("idempotent requests retry", func(t *testing.T) {
+		ft := &flakyTransport{failures: 2}
+		c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+		// Every attempt reached the server, so each needs a fresh token.
+		if _, err := c.PutContext(ctx, "foo", &pb.Link{Uri: "http://foo"}, PutOptions{}); err != nil {
+			t.Fatalf("PutContext failed after retries: %v", err)
+		}
+		if got := ft.calls.Load(); got != 3 {
+			t.Errorf("PutContext made %d attempts, want 3", got)
+		}
+	})
+
+	t.Run("gives up after MaxAttempts", func(t *testing.T) {
+		ft := &flakyTransport{failures: 3}
+		c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+		_, _, err := c.GetContext(ctx, "foo")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("GetContext returned %v, want the last 503", err)
+		}
+		if got := ft.calls.Load(); got != 3 {
+			t.Errorf("GetContext made %d attempts, want 3", got)
+		}
+	})
+
+	t.Run("posts don't retry", func(t *testing.T) {
+		ft := &flakyTransport{failures: 1}
+		c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+		if _, err := c.CreateContext(ctx, "", &pb.Link{Uri: "http://once"}); err == nil {
+			t.Error("CreateContext succeeded, want the 503")
+		}
+		if got := ft.calls.Load(); got != 1 {
+			t.Errorf("CreateContext made %d attempts, want 1", got)
+		}
+	})
+
+	// flakyTransport drops the response after the server applied the
+	// request, so a retry finds the change already made.
+	t.Run("deletes retry without a false 404", func(t *testing.T) {
+		if err := New(s.URL, signer).Put("gone", "http://gone"); err != nil {
+			t.Fatal(err)
+		}
+		ft := &flakyTransport{failures: 1}
+		c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+		if err := c.DeleteContext(ctx, "gone"); err != nil {
+			t.Errorf("DeleteContext failed after a retry: %v", err)
+		}
+		if got := ft.calls.Load(); got != 2 {
+			t.Errorf("DeleteContext made %d attempts, want 2", got)
+		}
+	})
+
+	t.Run("conditional puts don't retry", func(t *testing.T) {
+		ft := &flakyTransport{failures: 1}
+		c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+		_, err := c.PutContext(ctx, "created", &pb.Link{Uri: "http://created"}, PutOptions{CreateOnly: true})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("PutContext returned %v, want the 503 rather than a retry's 412", err)
+		}
+		if got := ft.calls.Load(); got != 1 {
+			t.Errorf("PutContext made %d attempts, want 1", got)
+		}
+	})
+
+	t.Run("client errors don't retry", func(t *testing.T) {
+		ft := &flakyTransport{}
+		c := NewWithOptions(s.URL, signer, Options{Transport: ft, Retry: fastRetry})
+		if _, _, err := c.GetContext(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetContext(missing) returned %v, want %v", err, ErrNotFound)
+		}
+		if got := ft.calls.Load(); got != 1 {
+			t.Errorf("GetContext(missing) made %d attempts, want 1", got)
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(s.Close)
+
+	c := NewWithOptions(s.URL, nil, Options{Timeout: 10 * time.Millisecond, Retry: fastRetry})
+	if _, err := c.ExportContext(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ExportContext returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	// A timed out attempt is retried, since the context allows it.
+	if got := calls.Load(); got != 3 {
+		t.Errorf("ExportContext made %d attempts, want 3", got)
+	}
+
+	// A done context stops the retries.
+	calls.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ExportContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ExportContext with a canceled context returned %v, want %v", err, context.Canceled)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("ExportContext with a canceled context made %d attempts, want 0", got)
+	}
+}
+
+func TestContextMethodsReturnProtos(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+	ctx := context.Background()
+
+	want := &pb.Link{Uri: "http://foo", Managed: true}
+	etag, err := c.PutContext(ctx, "foo", want, PutOptions{})
+	if err != nil {
+		t.Fatalf("PutContext failed: %v", err)
+	}
+	got, gotETag, err := c.GetContext(ctx, "foo")
+	if err != nil {
+		t.Fatalf("GetContext failed: %v", err)
+	}
+	if got.GetUri() != want.GetUri() || !got.GetManaged() || gotETag != etag {
+		t.Errorf("GetContext = %v, %q; want %v, %q", got, gotETag, want, etag)
+	}
+	all, err := c.ListContext(ctx)
+	if err != nil {
+		t.Fatalf("ListContext failed: %v", err)
+	}
+	if len(all) != 1 || !all["foo"].GetManaged() {
+		t.Errorf("ListContext = %v, want foo as managed", all)
+	}
+	if err := c.RenameContext(ctx, "foo", "bar", RenameOptions{}); err != nil {
+		t.Fatalf("RenameContext failed: %v", err)
+	}
+	if err := c.DeleteContext(ctx, "bar"); err != nil {
+		t.Fatalf("DeleteContext failed: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"net/http"
+
+	"connectrpc.com/connect"
+	"jdtw.dev/links/proto/links/linksconnect"
+	"jdtw.dev/token"
+)
+
+// NewServiceClient returns a typed client for the LinksService RPC API of
+// the server at host. Like Client, it signs every request with signer, or
+// sends unauthenticated requests if signer is nil.
+func NewServiceClient(host string, signer *token.SigningKey, opts ...connect.ClientOption) linksconnect.LinksServiceClient {
+	hc := &http.Client{Transport: &signingTransport{signer: signer, base: http.DefaultTransport}}
+	return linksconnect.NewLinksServiceClient(hc, host, opts...)
+}
+
+// signingTransport adds a fresh token to every request, since the server
+// rejects reused nonces.
+type signingTransport struct {
+	signer *token.SigningKey
+	base   http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.signer == nil {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper mustn't modify the request it was given.
+	req = req.Clone(req.Context())
+	if _, err := t.signer.AuthorizeRequest(req, tokenLifetime); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"jdtw.dev/links/pkg/diff"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// Plan compares desired, a map of link names to URIs, with the links on the
+// server and returns the changes that Apply would make to reconcile them.
+//
+// Every link in desired is managed: Apply marks it as such on the server.
+// Links on the server that desired doesn't mention are only deleted if they
+// are managed, so links created by hand are never removed by a sync. A
+// hand-made link that desired does mention is adopted, and shows up as an
+// update even if its URI already matches.
+func (c *Client) Plan(desired map[string]string) (*pb.LinksDiff, error) {
+	return c.PlanContext(context.Background(), desired)
+}
+
+// PlanContext is Plan with a context.
+func (c *Client) PlanContext(ctx context.Context, desired map[string]string) (*pb.LinksDiff, error) {
+	want := make(map[string]*pb.Link, len(desired))
+	for k, uri := range desired {
+		nk := normalizeKey(k)
+		if _, dup := want[nk]; dup {
+			return nil, fmt.Errorf("link %q is listed twice (the server ignores dashes in names)", nk)
+		}
+		want[nk] = &pb.Link{Uri: uri, Managed: true}
+	}
+
+	current, err := c.ExportContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return diff.Links(current.GetLinks(), want, (*pb.Link).GetManaged), nil
+}
+
+// Apply makes the changes in a plan returned by Plan. Additions and updates
+// are imported in one atomic request; deletions follow, one request each.
+func (c *Client) Apply(plan *pb.LinksDiff) error {
+	return c.ApplyContext(context.Background(), plan)
+}
+
+// ApplyContext is Apply with a context.
+func (c *Client) ApplyContext(ctx context.Context, plan *pb.LinksDiff) error {
+	lpb := &pb.Links{Links: make(map[string]*pb.Link)}
+	for _, changes := range [][]*pb.LinkChange{plan.GetAdded(), plan.GetUpdated()} {
+		for _, ch := range changes {
+			lpb.Links[ch.GetKey()] = ch.GetNew()
+		}
+	}
+	if len(lpb.Links) > 0 {
+		if _, err := c.ImportContext(ctx, lpb, ImportOptions{}); err != nil {
+			return err
+		}
+	}
+	for _, ch := range plan.GetDeleted() {
+		// A link someone else deleted in the meantime is as good as ours.
+		if err := c.DeleteContext(ctx, ch.GetKey()); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("deleting %q: %w", ch.GetKey(), err)
+		}
+	}
+	return nil
+}
+
+// HasChanges reports whether applying plan would change anything.
+func HasChanges(plan *pb.LinksDiff) bool {
+	return len(plan.GetAdded()) > 0 || len(plan.GetUpdated()) > 0 || len(plan.GetDeleted()) > 0
+}
+
+// normalizeKey mirrors the server's handling of link names, so that a plan
+// compares names the way the server stores them.
+func normalizeKey(k string) string {
+	return strings.ReplaceAll(strings.TrimSpace(k), "-", "")
+}
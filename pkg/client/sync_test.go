@@ -0,0 +1,73 @@
+package client
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"jdtw.dev/links/pkg/links"
+	"jdtw.dev/links/pkg/tokentest"
+)
+
+func TestSync(t *testing.T) {
+	ks, signer := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(links.NewHandler(links.NewMemStore(), ks, 0))
+	t.Cleanup(s.Close)
+	c := New(s.URL, signer)
+
+	// Hand-made links: one the file will adopt, one it doesn't mention.
+	for k, uri := range map[string]string{"adopted": "http://adopted", "manual": "http://manual"} {
+		if err := c.Put(k, uri); err != nil {
+			t.Fatalf("client.Put(%s) failed: %v", k, err)
+		}
+	}
+
+	plan, err := c.Plan(map[string]string{"adopted": "http://adopted", "stale": "http://stale"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.GetAdded()) != 1 || len(plan.GetUpdated()) != 1 || len(plan.GetDeleted()) != 0 {
+		t.Fatalf("Plan = %v, want stale added and adopted updated", plan)
+	}
+	if err := c.Apply(plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	// Dropping stale from the file deletes it, since the sync created it,
+	// but manual is never touched. Dashes are ignored as on the server.
+	plan, err = c.Plan(map[string]string{"adop-ted": "http://adopted"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.GetAdded()) != 0 || len(plan.GetUpdated()) != 0 || len(plan.GetUnchanged()) != 1 {
+		t.Errorf("Plan = %v, want adopted unchanged", plan)
+	}
+	if d := plan.GetDeleted(); len(d) != 1 || d[0].GetKey() != "stale" {
+		t.Errorf("Plan deleted = %v, want only stale", d)
+	}
+	if err := c.Apply(plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	got, err := c.List()
+	if err != nil {
+		t.Fatalf("client.List failed: %v", err)
+	}
+	if len(got) != 2 || got["adopted"] != "http://adopted" || got["manual"] != "http://manual" {
+		t.Errorf("after sync the server holds %v, want adopted and manual", got)
+	}
+
+	plan, err = c.Plan(map[string]string{"adopted": "http://adopted"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if HasChanges(plan) {
+		t.Errorf("Plan after Apply = %v, want no changes", plan)
+	}
+}
+
+func TestPlanRejectsDuplicateKeys(t *testing.T) {
+	c := New("http://unused", nil)
+	if _, err := c.Plan(map[string]string{"foo-bar": "http://a", "foobar": "http://b"}); err == nil {
+		t.Error("Plan with foo-bar and foobar succeeded, want an error")
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+// skewTolerance is how far apart the clocks can be before describeSkew
+// mentions it. The Date header only has a resolution of one second, so
+// anything closer can't be told apart from no skew at all.
+const skewTolerance = 2 * time.Second
+
+// Identity is who the server takes the client to be.
+type Identity struct {
+	// Subject is the subject of the key the client signs with.
+	Subject string
+	// ServerTime is the server's clock when it handled the request.
+	ServerTime time.Time
+	// ClockSkew estimates how far the local clock is ahead of the server's
+	// (behind, if negative).
+	ClockSkew time.Duration
+}
+
+// WhoAmI asks the server who it takes the client to be, to debug keys and
+// clocks. If the server rejects the request, the *APIError says whether the
+// clocks are far enough apart to be the cause.
+func (c *Client) WhoAmI() (*Identity, error) {
+	return c.WhoAmIContext(context.Background())
+}
+
+// WhoAmIContext is WhoAmI with a context.
+func (c *Client) WhoAmIContext(ctx context.Context) (*Identity, error) {
+	start := time.Now()
+	resp, err := c.do(ctx, "GET", "/api/whoami", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	wpb := &pb.WhoAmIResponse{}
+	if err := unmarshalBody(resp, wpb); err != nil {
+		return nil, err
+	}
+	// Compare with the local time halfway through the round trip, the best
+	// guess at when the server read its clock.
+	local := start.Add(time.Since(start) / 2)
+	serverTime := wpb.GetServerTime().AsTime()
+	return &Identity{
+		Subject:    wpb.GetSubject(),
+		ServerTime: serverTime,
+		ClockSkew:  local.Sub(serverTime),
+	}, nil
+}
+
+// describeSkew describes a clock skew as estimated by ClockSkew, or returns
+// "" if it is too small to matter.
+func describeSkew(skew time.Duration) string {
+	switch {
+	case skew >= skewTolerance:
+		return fmt.Sprintf("local clock is %v ahead of the server's", skew.Round(time.Second))
+	case skew <= -skewTolerance:
+		return fmt.Sprintf("local clock is %v behind the server's", (-skew).Round(time.Second))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,43 @@
+// Package diff computes the changes that would bring one set of links in
+// line with another. The server's dry-run imports and the client's sync
+// plans both come from here, so that they can't disagree about what a
+// change is.
+package diff
+
+import (
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// Links describes what writing desired over current would do. Both maps are
+// keyed by normalized link names. Links in current that desired doesn't
+// mention are reported as deleted if deleted returns true for them; a nil
+// deleted leaves them all alone. Every list in the result is sorted by key.
+func Links(current, desired map[string]*pb.Link, deleted func(*pb.Link) bool) *pb.LinksDiff {
+	d := &pb.LinksDiff{}
+	for k, l := range desired {
+		old, ok := current[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, &pb.LinkChange{Key: k, New: l})
+		case proto.Equal(old, l):
+			d.Unchanged = append(d.Unchanged, &pb.LinkChange{Key: k, Old: old, New: l})
+		default:
+			d.Updated = append(d.Updated, &pb.LinkChange{Key: k, Old: old, New: l})
+		}
+	}
+	if deleted != nil {
+		for k, old := range current {
+			if _, ok := desired[k]; !ok && deleted(old) {
+				d.Deleted = append(d.Deleted, &pb.LinkChange{Key: k, Old: old})
+			}
+		}
+	}
+
+	for _, changes := range [][]*pb.LinkChange{d.Added, d.Updated, d.Deleted, d.Unchanged} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	}
+	return d
+}
@@ -0,0 +1,58 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+func keys(changes []*pb.LinkChange) []string {
+	var ks []string
+	for _, ch := range changes {
+		ks = append(ks, ch.GetKey())
+	}
+	return ks
+}
+
+func TestLinks(t *testing.T) {
+	current := map[string]*pb.Link{
+		"same":    {Uri: "http://example.com/same"},
+		"changed": {Uri: "http://example.com/old"},
+		"managed": {Uri: "http://example.com/managed", Managed: true},
+		"byhand":  {Uri: "http://example.com/byhand"},
+	}
+	desired := map[string]*pb.Link{
+		"same":    {Uri: "http://example.com/same"},
+		"changed": {Uri: "http://example.com/new"},
+		"b":       {Uri: "http://example.com/b"},
+		"a":       {Uri: "http://example.com/a"},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		deleted func(*pb.Link) bool
+		want    []string
+	}{
+		{"additive", nil, nil},
+		{"replace", func(*pb.Link) bool { return true }, []string{"byhand", "managed"}},
+		{"managed only", (*pb.Link).GetManaged, []string{"managed"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := Links(current, desired, tc.deleted)
+			for _, c := range []struct {
+				what      string
+				got, want []string
+			}{
+				{"added", keys(d.GetAdded()), []string{"a", "b"}},
+				{"updated", keys(d.GetUpdated()), []string{"changed"}},
+				{"unchanged", keys(d.GetUnchanged()), []string{"same"}},
+				{"deleted", keys(d.GetDeleted()), tc.want},
+			} {
+				if !slices.Equal(c.got, c.want) {
+					t.Errorf("%s = %v, want %v", c.what, c.got, c.want)
+				}
+			}
+		})
+	}
+}
@@ -2,9 +2,11 @@ package frontend
 
 import (
 	"embed"
+	"errors"
 	"html/template"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 
@@ -26,7 +28,11 @@ const html = `
 </head>
 <body>
 <h1>➕ Add Link</h1>
+{{if .ReadOnly}}
+<p id="read-only">🔒 Links are read-only for now; they can't be added, edited or removed.</p>
+{{end}}
 <form id="link-form" method="POST">
+  <fieldset {{if .ReadOnly}}disabled{{end}}>
   <table>
   <tr>
     <td><label>Link:</label></td>
@@ -38,20 +44,21 @@ const html = `
   </tr>
   </table>
   <input type="submit">
+  </fieldset>
 </form>
 <h1>🔗 Links</h1>
 <table id="links">
   <tr><th>Link</th><th></th><th></th><th>URI</th></tr>
-  {{range .}}
+  {{range .Links}}
   <tr>
-    <td>{{.Link}}</td>
-    <td><button title="Edit" data-edit="{{.Link}}">🖋️️</button></td>
-    <td><button title="Delete" data-remove="{{.Link}}">❌</button></td>
+    <td><a href="{{.Short}}">{{.Link}}</a></td>
+    <td><button title="Edit" data-edit="{{.Link}}" {{if $.ReadOnly}}disabled{{end}}>🖋️️</button></td>
+    <td><button title="Delete" data-remove="{{.Link}}" {{if $.ReadOnly}}disabled{{end}}>❌</button></td>
     <td><a id="{{.Link}}" href="{{.URI}}">{{.URI}}</a></td>
   </tr>
   {{end}}
 </table>
-<script src="/static/links.js"></script>
+<script src="static/links.js"></script>
 </body>
 </html>
 `
@@ -85,19 +92,32 @@ func (s *server) addLink() http.HandlerFunc {
 				http.Error(w, "missing link or URI", http.StatusBadRequest)
 				return
 			}
-			if err := s.cli.Put(link, uri); err != nil {
-				log.Printf("Put link failed: %v", err)
+			if err := s.cli.Put(link, uri); errors.Is(err, client.ErrReadOnly) {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			} else if err != nil {
+				slog.Error("put link failed", "link", link, "uri", uri, "error", err)
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
 		m, err := s.cli.List()
 		if err != nil {
-			log.Printf("List links failed: %v", err)
+			slog.Error("list links failed", "error", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if err := tmpl.Execute(w, sortLinks(m)); err != nil {
+		// The server refuses writes while read-only whatever the page
+		// shows, so if it can't say, offer them anyway.
+		readOnly, err := s.cli.ReadOnly()
+		if err != nil {
+			slog.Warn("read-only status unknown", "error", err)
+		}
+		page := struct {
+			ReadOnly bool
+			Links    []*link
+		}{readOnly, sortLinks(s.cli.Host, m)}
+		if err := tmpl.Execute(w, page); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
@@ -106,23 +126,33 @@ func (s *server) addLink() http.HandlerFunc {
 func (s *server) removeLink() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		link := chi.URLParam(r, "link")
-		if err := s.cli.Delete(link); err != nil {
+		if err := s.cli.Delete(link); errors.Is(err, client.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if errors.Is(err, client.ErrReadOnly) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		log.Printf("Removed %s", link)
+		slog.Info("removed link", "link", link)
 	}
 }
 
 type link struct {
 	Link string
 	URI  string
+	// Short is the link's URL on the links server, which may be mounted
+	// under a path of its own.
+	Short string
 }
 
-func sortLinks(m map[string]string) []*link {
+func sortLinks(host string, m map[string]string) []*link {
+	host = strings.TrimSuffix(host, "/")
 	ls := make([]*link, 0, len(m))
 	for k, v := range m {
-		ls = append(ls, &link{k, v})
+		ls = append(ls, &link{k, v, host + "/" + url.PathEscape(k)})
 	}
 	sort.SliceStable(ls, func(i, j int) bool {
 		return strings.Compare(ls[i].Link, ls[j].Link) < 0
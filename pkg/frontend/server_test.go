@@ -127,3 +127,57 @@ func TestRemoveLinkAllowsSameOrigin(t *testing.T) {
 		t.Fatalf("got status %d, want %d", sc, http.StatusOK)
 	}
 }
+
+func TestLinksPointAtMountedBackend(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	mux := http.NewServeMux()
+	mux.Handle("/go/", http.StripPrefix("/go", links.NewHandler(links.NewMemStore(), keyset, 0)))
+	backend := httptest.NewServer(mux)
+	t.Cleanup(backend.Close)
+	srv := NewHandler(client.New(backend.URL+"/go", priv))
+
+	req, rr := postForm("foo", "http://example.com")
+	req.Header.Set("Origin", "http://example.com")
+	srv.ServeHTTP(rr, req)
+	if sc := rr.Result().StatusCode; sc != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", sc, http.StatusOK, rr.Body)
+	}
+	if want := `href="` + backend.URL + `/go/foo"`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("page doesn't link to the short URL (%s):\n%s", want, rr.Body)
+	}
+	if strings.Contains(rr.Body.String(), `src="/`) {
+		t.Errorf("page loads a script from an absolute path, which breaks under a prefix:\n%s", rr.Body)
+	}
+}
+
+func TestReadOnlyDisablesForm(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	backend := httptest.NewServer(links.New(links.NewMemStore(), links.WithKeyset(keyset), links.WithReadOnly(true)))
+	t.Cleanup(backend.Close)
+	srv := NewHandler(client.New(backend.URL, priv))
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if sc := rr.Result().StatusCode; sc != http.StatusOK {
+		t.Fatalf("GET / returned %d, want %d: %s", sc, http.StatusOK, rr.Body)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "<fieldset disabled>") || !strings.Contains(body, "read-only") {
+		t.Errorf("page for a read-only server doesn't disable the form:\n%s", body)
+	}
+
+	req, rr := postForm("foo", "http://example.com")
+	req.Header.Set("Origin", "http://example.com")
+	srv.ServeHTTP(rr, req)
+	if sc := rr.Result().StatusCode; sc != http.StatusServiceUnavailable {
+		t.Errorf("POST to a read-only server returned %d, want %d", sc, http.StatusServiceUnavailable)
+	}
+}
+
+func TestWritableFormIsEnabled(t *testing.T) {
+	srv := newTestServer(t)
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if body := rr.Body.String(); strings.Contains(body, "disabled") {
+		t.Errorf("page for a writable server disables something:\n%s", body)
+	}
+}
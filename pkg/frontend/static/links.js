@@ -1,6 +1,6 @@
 async function removeLink(remove, target) {
   if (!confirm(`Remove ${remove}?`)) return;
-  let resp = await fetch(`/rm/${remove}`, { method: "DELETE" });
+  let resp = await fetch(`rm/${remove}`, { method: "DELETE" });
   if (!resp.ok) {
     let err = await resp.text();
     alert(err)
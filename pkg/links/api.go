@@ -3,60 +3,40 @@ package links
 import (
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"sort"
-	"strings"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"google.golang.org/protobuf/encoding/protojson"
 	pb "jdtw.dev/links/proto/links"
 )
 
 func (s *server) list() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rid := middleware.GetReqID(r.Context())
-
 		lpb := &pb.Links{
 			Links: make(map[string]*pb.Link),
 		}
 		s.store.Visit(r.Context(), func(k string, v *pb.LinkEntry) {
 			lpb.Links[k] = v.Link
 		})
-		data, err := protojson.Marshal(lpb)
-		if err != nil {
-			internalError(w, err, rid)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(data)
+		writeBody(w, r, http.StatusOK, lpb)
 	}
 }
 
 func (s *server) get() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rid := middleware.GetReqID(r.Context())
-
 		l := normalizeKey(chi.URLParam(r, "link"))
 		lepb, err := s.store.Get(r.Context(), l)
 		if err != nil {
-			internalError(w, err, rid)
+			internalError(w, r, err)
 			return
 		}
 		if lepb == nil {
-			http.NotFound(w, r)
+			notFound(w, r, l)
 			return
 		}
-		data, err := protojson.Marshal(lepb.Link)
-		if err != nil {
-			internalError(w, err, rid)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(data)
+		w.Header().Set("ETag", etag(lepb.Link))
+		writeBody(w, r, http.StatusOK, lepb.Link)
 	}
 }
 
@@ -65,8 +45,8 @@ func (s *server) get() http.HandlerFunc {
 // bulkPut() so a bulk import enforces exactly the same rules as a single
 // write.
 func validateLink(key string, l *pb.Link) error {
-	if key == qrKey {
-		return fmt.Errorf("%q is a reserved link name", qrKey)
+	if err := validateKey(key); err != nil {
+		return err
 	}
 	if l.GetUri() == "" {
 		return errors.New("missing URI")
@@ -84,120 +64,256 @@ func validateLink(key string, l *pb.Link) error {
 	return nil
 }
 
+// normalizeLinks normalizes the keys of a bulk import and validates every
+// link, so that nothing is written unless all of it can be. Keys that
+// collide only after normalization ("my-link" and "mylink") would silently
+// overwrite each other, so those are rejected too. The error lists every
+// problem found.
+func normalizeLinks(links map[string]*pb.Link) (map[string]*pb.Link, error) {
+	normalized := make(map[string]*pb.Link, len(links))
+	sources := make(map[string]string, len(links))
+	invalid := &invalidLinksError{total: len(links)}
+	for k, l := range links {
+		key := normalizeKey(k)
+		if err := validateLink(key, l); err != nil {
+			invalid.problems = append(invalid.problems, problem{k, err.Error()})
+			continue
+		}
+		if prev, dup := sources[key]; dup {
+			invalid.problems = append(invalid.problems, problem{k, fmt.Sprintf("collides with %q after normalization", prev)})
+			continue
+		}
+		sources[key] = k
+		normalized[key] = l
+	}
+	if len(invalid.problems) > 0 {
+		invalid.sort()
+		return nil, invalid
+	}
+	return normalized, nil
+}
+
+// validateKey is the part of validateLink that concerns the key alone.
+func validateKey(key string) error {
+	if reservedKeys[key] {
+		return fmt.Errorf("%q is a reserved link name", key)
+	}
+	return nil
+}
+
 func (s *server) put() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rid := middleware.GetReqID(r.Context())
-
 		l := normalizeKey(chi.URLParam(r, "link"))
-		data, err := io.ReadAll(r.Body)
-		if err != nil {
-			internalError(w, err, rid)
-			return
-		}
 		lpb := new(pb.Link)
-		if err := protojson.Unmarshal(data, lpb); err != nil {
-			badRequest(w, "failed to unmarshal body: %v", err)
+		if !readBody(w, r, lpb) {
 			return
 		}
 		if err := validateLink(l, lpb); err != nil {
-			badRequest(w, "%v", err)
+			badRequest(w, r, "%v", err)
 			return
 		}
-		created, err := s.store.Put(r.Context(), l, lpb)
+		var created bool
+		var err error
+		if conditional(r) {
+			var swapped bool
+			swapped, created, err = s.swapIfMatch(r, l, lpb)
+			if err == nil && !swapped {
+				preconditionFailed(w, r)
+				return
+			}
+		} else {
+			created, err = s.store.Put(r.Context(), l, lpb)
+		}
 		if err != nil {
-			internalError(w, err, rid)
+			storeError(w, r, err)
 			return
 		}
 
-		sub := subject(r.Context())
+		w.Header().Set("ETag", etag(lpb))
+		log := logger(r.Context()).With("key", l, "target", lpb.Uri)
 		if created {
 			w.WriteHeader(http.StatusCreated)
-			log.Printf("[%s] %s added %q -> %q", rid, sub, l, lpb.Uri)
+			log.Info("link added")
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
-		log.Printf("[%s] %s updated %q -> %q", rid, sub, l, lpb.Uri)
+		log.Info("link updated")
 	}
 }
 
 // bulkPut creates or updates every link in the request body, which is a
-// Links proto of the same shape that list() returns. Links already in the
-// store that the body does not mention are left alone, so an import is
-// additive rather than a replacement.
+// Links proto of the same shape that list() returns. By default, links
+// already in the store that the body does not mention are left alone, so an
+// import is additive; with mode=replace they are deleted, so that the store
+// ends up holding exactly the body. With dry_run=true nothing is written,
+// and the response is a LinksDiff describing what the import would do.
 //
-// Every entry is validated before anything is written: one malformed link
-// fails the whole request rather than leaving a half-applied import.
+// Every entry is validated before anything is written, and the writes are
+// applied with a single Store.PutAll or Store.ReplaceAll: neither a
+// malformed link nor a storage error part way through can leave a
+// half-applied import behind.
 func (s *server) bulkPut() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rid := middleware.GetReqID(r.Context())
-
-		data, err := io.ReadAll(r.Body)
+		var replace bool
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", "merge":
+		case "replace":
+			replace = true
+		default:
+			badRequest(w, r, "unknown mode %q; want merge or replace", mode)
+			return
+		}
+		dryRun, err := boolParam(r, "dry_run")
 		if err != nil {
-			internalError(w, err, rid)
+			badRequest(w, r, "%v", err)
 			return
 		}
+
 		lpb := new(pb.Links)
-		if err := protojson.Unmarshal(data, lpb); err != nil {
-			badRequest(w, "failed to unmarshal body: %v", err)
+		if !readBody(w, r, lpb) {
 			return
 		}
 		if len(lpb.GetLinks()) == 0 {
-			badRequest(w, "no links in request body")
+			badRequest(w, r, "no links in request body")
 			return
 		}
 
-		// Normalize and validate everything before the first write. Keys
-		// that collide only after normalization ("my-link" and "mylink")
-		// would silently overwrite each other, so reject those too.
-		normalized := make(map[string]*pb.Link, len(lpb.GetLinks()))
-		sources := make(map[string]string, len(lpb.GetLinks()))
-		var problems []string
-		for k, l := range lpb.GetLinks() {
-			key := normalizeKey(k)
-			if err := validateLink(key, l); err != nil {
-				problems = append(problems, fmt.Sprintf("%q: %v", k, err))
-				continue
+		// Normalize and validate everything before the first write.
+		normalized, err := normalizeLinks(lpb.GetLinks())
+		if err != nil {
+			var ile *invalidLinksError
+			if errors.As(err, &ile) {
+				writeError(w, r, http.StatusBadRequest, ile.summary(), ile.problems...)
+			} else {
+				badRequest(w, r, "%v", err)
 			}
-			if prev, dup := sources[key]; dup {
-				problems = append(problems, fmt.Sprintf("%q: collides with %q after normalization", k, prev))
-				continue
+			return
+		}
+
+		if dryRun {
+			diff, err := diffLinks(r.Context(), s.store, normalized, replace)
+			if err != nil {
+				internalError(w, r, err)
+				return
 			}
-			sources[key] = k
-			normalized[key] = l
+			writeBody(w, r, http.StatusOK, diff)
+			return
+		}
+
+		var created, updated, deleted int
+		if replace {
+			created, updated, deleted, err = s.store.ReplaceAll(r.Context(), normalized)
+		} else {
+			created, updated, err = s.store.PutAll(r.Context(), normalized)
 		}
-		if len(problems) > 0 {
-			sort.Strings(problems)
-			badRequest(w, "rejected %d of %d links:\n%s", len(problems), len(lpb.GetLinks()), strings.Join(problems, "\n"))
+		if err != nil {
+			storeError(w, r, err)
 			return
 		}
 
-		var created, updated int
-		for k, l := range normalized {
-			wasCreated, err := s.store.Put(r.Context(), k, l)
+		w.WriteHeader(http.StatusNoContent)
+		logger(r.Context()).Info("links imported", "replace", replace,
+			"count", len(normalized), "created", created, "updated", updated, "deleted", deleted)
+	}
+}
+
+// delete removes a link. Deleting a link that doesn't exist is a 404, since
+// it's usually a typo, unless the request sets missing_ok=true to make the
+// delete idempotent.
+func (s *server) delete() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := normalizeKey(chi.URLParam(r, "link"))
+		missingOK, err := boolParam(r, "missing_ok")
+		if err != nil {
+			badRequest(w, r, "%v", err)
+			return
+		}
+		if conditional(r) {
+			swapped, _, err := s.swapIfMatch(r, l, nil)
 			if err != nil {
-				internalError(w, err, rid)
+				storeError(w, r, err)
 				return
 			}
-			if wasCreated {
-				created++
-			} else {
-				updated++
+			if !swapped {
+				preconditionFailed(w, r)
+				return
+			}
+		} else {
+			deleted, err := s.store.Delete(r.Context(), l)
+			if err != nil {
+				storeError(w, r, err)
+				return
+			}
+			if !deleted && !missingOK {
+				notFound(w, r, l)
+				return
 			}
 		}
-
 		w.WriteHeader(http.StatusNoContent)
-		log.Printf("[%s] %s imported %d links (%d created, %d updated)",
-			rid, subject(r.Context()), len(normalized), created, updated)
+		logger(r.Context()).Info("link deleted", "key", l)
 	}
 }
 
-func (s *server) delete() http.HandlerFunc {
+// rename moves a link to the key in the "to" query parameter, in one atomic
+// step so that the link is never missing or duplicated part way through.
+// If the new key is taken the rename fails (409) unless overwrite=true.
+// With alias=true the old key keeps a copy of the link, so that URLs
+// already handed out keep working.
+func (s *server) rename() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rid := middleware.GetReqID(r.Context())
+		from := normalizeKey(chi.URLParam(r, "link"))
+		to := normalizeKey(r.URL.Query().Get("to"))
+		if to == "" {
+			badRequest(w, r, "missing new key")
+			return
+		}
+		if to == from {
+			badRequest(w, r, "%q is already the link's key", to)
+			return
+		}
+		// The URI was validated when the link was stored, so only the new
+		// key needs checking.
+		if err := validateKey(to); err != nil {
+			badRequest(w, r, "%v", err)
+			return
+		}
+		overwrite, err := boolParam(r, "overwrite")
+		if err != nil {
+			badRequest(w, r, "%v", err)
+			return
+		}
+		alias, err := boolParam(r, "alias")
+		if err != nil {
+			badRequest(w, r, "%v", err)
+			return
+		}
 
-		l := normalizeKey(chi.URLParam(r, "link"))
-		s.store.Delete(r.Context(), l)
+		switch err := s.store.Rename(r.Context(), from, to, overwrite, alias); {
+		case errors.Is(err, ErrNotFound):
+			notFound(w, r, from)
+			return
+		case errors.Is(err, ErrExists):
+			alreadyExists(w, r, to)
+			return
+		case err != nil:
+			storeError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", linkPath(r.Context(), to))
 		w.WriteHeader(http.StatusNoContent)
-		log.Printf("[%s] %s deleted %q", rid, subject(r.Context()), l)
+		logger(r.Context()).Info("link renamed", "key", from, "new_key", to, "overwrite", overwrite, "alias", alias)
+	}
+}
+
+// boolParam parses the named query parameter, which defaults to false.
+func boolParam(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %v", name, v, err)
 	}
+	return b, nil
 }
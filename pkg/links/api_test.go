@@ -2,7 +2,9 @@ package links
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -47,7 +49,8 @@ func TestNilKeysetFailsClosed(t *testing.T) {
 	}{{"GET", "/api/links"},
 		{"PUT", "/api/links/foo"},
 		{"GET", "/api/links/foo"},
-		{"DELETE", "/api/links/foo"}}
+		{"DELETE", "/api/links/foo"},
+		{"GET", "/api/whoami"}}
 
 	_, priv := tokentest.GenerateKey(t, "test")
 	srv := NewHandler(NewMemStore(), nil, 0)
@@ -69,7 +72,8 @@ func TestUnsignedRequestFails(t *testing.T) {
 	}{{"GET", "/api/links"},
 		{"PUT", "/api/links/foo"},
 		{"GET", "/api/links/foo"},
-		{"DELETE", "/api/links/foo"}}
+		{"DELETE", "/api/links/foo"},
+		{"GET", "/api/whoami"}}
 
 	keyset, _ := tokentest.GenerateKey(t, "test")
 	srv := NewHandler(NewMemStore(), keyset, 0)
@@ -90,7 +94,8 @@ func TestUntrustedKeyFails(t *testing.T) {
 	}{{"GET", "/api/links"},
 		{"PUT", "/api/links/foo"},
 		{"GET", "/api/links/foo"},
-		{"DELETE", "/api/links/foo"}}
+		{"DELETE", "/api/links/foo"},
+		{"GET", "/api/whoami"}}
 
 	keyset, _ := tokentest.GenerateKey(t, "test")
 	_, priv := tokentest.GenerateKey(t, "evil")
@@ -106,6 +111,28 @@ func TestUntrustedKeyFails(t *testing.T) {
 	}
 }
 
+func TestWhoAmI(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "alice")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	before := time.Now()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/whoami", nil)
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /api/whoami returned %d, want 200", rr.Code)
+	}
+	got := new(pb.WhoAmIResponse)
+	unmarshal(t, rr.Body, got)
+	if got.GetSubject() != "alice" {
+		t.Errorf("subject = %q, want alice", got.GetSubject())
+	}
+	if st := got.GetServerTime().AsTime(); st.Before(before) || st.After(time.Now()) {
+		t.Errorf("server time = %v, want the time of the request", st)
+	}
+}
+
 func TestCRUD(t *testing.T) {
 	keyset, priv := tokentest.GenerateKey(t, "test")
 	srv := NewHandler(NewMemStore(), keyset, 0)
@@ -222,6 +249,47 @@ func TestCRUD(t *testing.T) {
 			t.Errorf("GET %s returned %d, want 404", path, sc)
 		}
 	}()
+
+	// 9 ) Delete it again
+	func() {
+		res := serveHTTP("DELETE", path, nil)
+		if sc := res.StatusCode; sc != http.StatusNotFound {
+			t.Errorf("DELETE %s of a missing link returned %d, want 404", path, sc)
+		}
+	}()
+
+	// 10 ) ...unless that's fine with the caller
+	func() {
+		res := serveHTTP("DELETE", path+"?missing_ok=true", nil)
+		if sc := res.StatusCode; sc != http.StatusNoContent {
+			t.Errorf("DELETE %s?missing_ok=true of a missing link returned %d, want 204", path, sc)
+		}
+		res = serveHTTP("DELETE", path+"?missing_ok=perhaps", nil)
+		if sc := res.StatusCode; sc != http.StatusBadRequest {
+			t.Errorf("DELETE %s?missing_ok=perhaps returned %d, want 400", path, sc)
+		}
+	}()
+}
+
+// brokenDeleteStore fails every Delete.
+type brokenDeleteStore struct {
+	*MemStore
+}
+
+func (brokenDeleteStore) Delete(ctx context.Context, k string) (bool, error) {
+	return false, errors.New("disk on fire")
+}
+
+func TestDeleteReportsStorageErrors(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(brokenDeleteStore{NewMemStore()}, keyset, 0)
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/api/links/foo?missing_ok=true", nil)
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("DELETE with a failing store returned %d, want 500", rr.Code)
+	}
 }
 
 func TestPutRejectsReservedQRKey(t *testing.T) {
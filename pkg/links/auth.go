@@ -3,45 +3,77 @@ package links
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
 
-	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	pb "jdtw.dev/links/proto/links"
 )
 
 var subjectCtxKey = &contextKey{"Subject"}
 
 func (s *server) authenticated() func(next http.Handler) http.Handler {
 	if s.ks == nil {
-		log.Printf("server missing keyset!")
+		slog.Error("server missing keyset!")
 		return func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				http.Error(w, "server missing keyset", http.StatusUnauthorized)
+				authFailures.Inc("no_keyset")
+				writeError(w, r, http.StatusUnauthorized, "server missing keyset")
 			})
 		}
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			rid := middleware.GetReqID(r.Context())
-			subject, _, err := s.ks.AuthorizeRequest(r, s.skew, s.nv)
+			subject, _, err := s.ks.AuthorizeRequest(requestedURL(r), s.skew, s.nv)
 			if err != nil {
-				if dump, dumpErr := httputil.DumpRequest(r, false); dumpErr == nil {
-					log.Printf("[%s] request unauthorized: %v\n%s", rid, err, dump)
-				}
-				http.Error(w, fmt.Sprintf("unauthorized: %v", err), http.StatusUnauthorized)
+				reason := authFailureReason(r)
+				authFailures.Inc(reason)
+				logger(r.Context()).Warn("request unauthorized",
+					"reason", reason,
+					"error", err,
+					"method", r.Method,
+					"host", r.Host,
+					"path", r.URL.Path,
+					"remote_addr", r.RemoteAddr,
+					"user_agent", r.UserAgent())
+				writeError(w, r, http.StatusUnauthorized, fmt.Sprintf("unauthorized: %v", err))
 				return
 			}
+			if rl, ok := r.Context().Value(requestLogCtxKey).(*requestLog); ok {
+				rl.subject = subject
+			}
 			ctx := context.WithValue(r.Context(), subjectCtxKey, subject)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// authFailureReason classifies a rejected request for metrics. The token
+// library's errors aren't typed, so all we can tell apart is whether the
+// client sent a token at all.
+func authFailureReason(r *http.Request) string {
+	if r.Header.Get("Authorization") == "" {
+		return "missing_token"
+	}
+	return "invalid_token"
+}
+
 func subject(ctx context.Context) string {
 	if user, ok := ctx.Value(subjectCtxKey).(string); ok {
 		return user
 	}
 	return ""
 }
+
+// whoami reports the subject the request authenticated as and the server's
+// time, so that a client can tell a key the server doesn't trust from a
+// clock too far from the server's.
+func (s *server) whoami() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeBody(w, r, http.StatusOK, &pb.WhoAmIResponse{
+			Subject:    subject(r.Context()),
+			ServerTime: timestamppb.New(s.now()),
+		})
+	}
+}
@@ -256,3 +256,157 @@ func TestExportImportRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+// A storage failure part way through must not leave a partial import
+// behind, which is what writing one key at a time did. The fault is raised
+// by the database inside the batch's transaction, so any keys written
+// before it have to be rolled back.
+func TestBulkPutIsAtomicOnStorageFailure(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := newTestSQLiteStore(t)
+	if _, err := store.db.ExecContext(context.Background(), `create trigger fail before insert on links
+		when new.path = 'boom' begin select raise(abort, 'injected fault'); end`); err != nil {
+		t.Fatalf("creating trigger failed: %v", err)
+	}
+	srv := NewHandler(store, keyset, 0)
+
+	res := postLinks(t, srv, priv, map[string]string{
+		"one":   "https://example.com/one",
+		"boom":  "https://example.com/boom",
+		"three": "https://example.com/three",
+	})
+	if sc := res.StatusCode; sc != http.StatusInternalServerError {
+		t.Fatalf("POST returned %d, want 500", sc)
+	}
+	if got := storedLinks(t, store); len(got) != 0 {
+		t.Errorf("a failed import wrote %v, want nothing", got)
+	}
+}
+
+func postQuery(t *testing.T, srv http.Handler, priv *token.SigningKey, query string, links map[string]string) *http.Response {
+	t.Helper()
+	lpb := &pb.Links{Links: make(map[string]*pb.Link, len(links))}
+	for k, uri := range links {
+		lpb.Links[k] = &pb.Link{Uri: uri}
+	}
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/links?"+query, marshal(t, lpb))
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	return rr.Result()
+}
+
+func storedLinks(t *testing.T, store Store) map[string]string {
+	t.Helper()
+	got := map[string]string{}
+	if err := store.Visit(context.Background(), func(k string, le *pb.LinkEntry) {
+		got[k] = le.GetLink().GetUri()
+	}); err != nil {
+		t.Fatalf("Visit failed: %v", err)
+	}
+	return got
+}
+
+func TestBulkPutReplaceMode(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	srv := NewHandler(store, keyset, 0)
+	ctx := context.Background()
+	store.Put(ctx, "stale", &pb.Link{Uri: "https://example.com/stale"})
+	store.Put(ctx, "kept", &pb.Link{Uri: "https://example.com/old"})
+
+	want := map[string]string{
+		"kept": "https://example.com/new",
+		"new":  "https://example.com/new",
+	}
+	if sc := postQuery(t, srv, priv, "mode=replace", want).StatusCode; sc != http.StatusNoContent {
+		t.Fatalf("POST ?mode=replace returned %d, want 204", sc)
+	}
+	got := storedLinks(t, store)
+	if len(got) != len(want) {
+		t.Errorf("after replace the store holds %v, want %v", got, want)
+	}
+	for k, uri := range want {
+		if got[k] != uri {
+			t.Errorf("after replace [%s] = %q, want %q", k, got[k], uri)
+		}
+	}
+}
+
+func TestBulkPutDryRun(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	srv := NewHandler(store, keyset, 0)
+	ctx := context.Background()
+	store.Put(ctx, "stale", &pb.Link{Uri: "https://example.com/stale"})
+	store.Put(ctx, "changed", &pb.Link{Uri: "https://example.com/old"})
+	store.Put(ctx, "same", &pb.Link{Uri: "https://example.com/same"})
+	before := storedLinks(t, store)
+
+	upload := map[string]string{
+		"changed": "https://example.com/new",
+		"same":    "https://example.com/same",
+		"added":   "https://example.com/added",
+	}
+	tests := []struct {
+		query       string
+		wantDeleted []string
+	}{
+		{"dry_run=true", nil},
+		{"dry_run=true&mode=replace", []string{"stale"}},
+	}
+	for _, tc := range tests {
+		res := postQuery(t, srv, priv, tc.query, upload)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("POST ?%s returned %d, want 200", tc.query, res.StatusCode)
+		}
+		diff := new(pb.LinksDiff)
+		unmarshal(t, res.Body, diff)
+
+		keys := func(changes []*pb.LinkChange) []string {
+			var ks []string
+			for _, c := range changes {
+				ks = append(ks, c.GetKey())
+			}
+			return ks
+		}
+		for _, check := range []struct {
+			name string
+			got  []string
+			want []string
+		}{
+			{"added", keys(diff.GetAdded()), []string{"added"}},
+			{"updated", keys(diff.GetUpdated()), []string{"changed"}},
+			{"deleted", keys(diff.GetDeleted()), tc.wantDeleted},
+			{"unchanged", keys(diff.GetUnchanged()), []string{"same"}},
+		} {
+			if strings.Join(check.got, ",") != strings.Join(check.want, ",") {
+				t.Errorf("POST ?%s %s = %v, want %v", tc.query, check.name, check.got, check.want)
+			}
+		}
+		if u := diff.GetUpdated(); len(u) == 1 && (u[0].GetOld().GetUri() != "https://example.com/old" || u[0].GetNew().GetUri() != "https://example.com/new") {
+			t.Errorf("POST ?%s updated = %v, want old and new URIs", tc.query, u[0])
+		}
+	}
+
+	// A dry run never writes.
+	after := storedLinks(t, store)
+	if len(after) != len(before) {
+		t.Errorf("dry run changed the store from %v to %v", before, after)
+	}
+	for k, uri := range before {
+		if after[k] != uri {
+			t.Errorf("dry run changed [%s] from %q to %q", k, uri, after[k])
+		}
+	}
+}
+
+func TestBulkPutRejectsBadOptions(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+	for _, query := range []string{"mode=overwrite", "dry_run=maybe"} {
+		if sc := postQuery(t, srv, priv, query, map[string]string{"foo": "https://example.com"}).StatusCode; sc != http.StatusBadRequest {
+			t.Errorf("POST ?%s returned %d, want 400", query, sc)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package links
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+// CachedStore is a Store that keeps recently resolved entries in an
+// in-process LRU cache, so that hot redirects don't pay a database round trip
+// every time. Misses are cached too (as nil entries), which keeps scanners
+// probing for nonexistent keys off the database as well.
+//
+// Writes made through the CachedStore invalidate the affected keys, so the
+// cache is only safe in front of a store that nothing else writes to. Entries
+// returned by Get are shared with the cache and must not be modified.
+type CachedStore struct {
+	store Store
+	size  int
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// gen is bumped by every write. A Get that misses records gen before
+	// going to the underlying store and only populates the cache if no
+	// write happened in the meantime, so a racing Put can't be
+	// overwritten by the stale value it replaced.
+	gen uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+var _ Store = &CachedStore{}
+
+type cacheEntry struct {
+	key     string
+	le      *pb.LinkEntry
+	expires time.Time
+}
+
+// CacheStats is a snapshot of a CachedStore's counters.
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+}
+
+// NewCachedStore wraps store with a cache holding at most size entries, each
+// for at most ttl. A ttl of zero means entries only leave the cache when they
+// are evicted or invalidated.
+func NewCachedStore(store Store, size int, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		store:   store,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *CachedStore) Get(ctx context.Context, k string) (*pb.LinkEntry, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[k]; ok {
+		ce := e.Value.(*cacheEntry)
+		if c.ttl == 0 || c.now().Before(ce.expires) {
+			c.lru.MoveToFront(e)
+			c.mu.Unlock()
+			c.hits.Add(1)
+			cacheHits.Inc()
+			return ce.le, nil
+		}
+		c.remove(e)
+	}
+	gen := c.gen
+	c.mu.Unlock()
+	c.misses.Add(1)
+	cacheMisses.Inc()
+
+	le, err := c.store.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen == gen {
+		c.add(k, le)
+	}
+	return le, nil
+}
+
+func (c *CachedStore) Put(ctx context.Context, k string, l *pb.Link) (bool, error) {
+	defer c.invalidate(k)
+	return c.store.Put(ctx, k, l)
+}
+
+func (c *CachedStore) PutAll(ctx context.Context, links map[string]*pb.Link) (int, int, error) {
+	defer func() {
+		for k := range links {
+			c.invalidate(k)
+		}
+	}()
+	return c.store.PutAll(ctx, links)
+}
+
+// ReplaceAll may delete keys it was never told about, so it empties the
+// cache rather than invalidating key by key.
+func (c *CachedStore) ReplaceAll(ctx context.Context, links map[string]*pb.Link) (int, int, int, error) {
+	defer c.invalidateAll()
+	return c.store.ReplaceAll(ctx, links)
+}
+
+func (c *CachedStore) CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error) {
+	defer c.invalidate(k)
+	return c.store.CompareAndSwap(ctx, k, old, new)
+}
+
+func (c *CachedStore) Delete(ctx context.Context, k string) (bool, error) {
+	defer c.invalidate(k)
+	return c.store.Delete(ctx, k)
+}
+
+func (c *CachedStore) Rename(ctx context.Context, from, to string, overwrite, alias bool) error {
+	defer c.invalidate(from)
+	defer c.invalidate(to)
+	return c.store.Rename(ctx, from, to, overwrite, alias)
+}
+
+// Visit always reads through to the underlying store; listing is rare and
+// must see every link, not just the cached ones.
+func (c *CachedStore) Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error {
+	return c.store.Visit(ctx, visit)
+}
+
+// Ping checks the underlying store, bypassing the cache.
+func (c *CachedStore) Ping(ctx context.Context) error {
+	return ping(ctx, c.store)
+}
+
+// Stats reports the cache's hit and miss counts and its current size. The
+// counts are also added to links_cache_hits_total and
+// links_cache_misses_total, which sum over every CachedStore in the process.
+func (c *CachedStore) Stats() CacheStats {
+	c.mu.Lock()
+	n := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: n,
+	}
+}
+
+// invalidate drops k from the cache after a write. It runs whether or not
+// the write succeeded, since a failed write may still have been applied.
+func (c *CachedStore) invalidate(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if e, ok := c.entries[k]; ok {
+		c.remove(e)
+	}
+}
+
+// invalidateAll empties the cache after a write that may touch any key.
+func (c *CachedStore) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
+
+// add inserts or refreshes k, evicting the least recently used entry if the
+// cache is full. The caller must hold c.mu.
+func (c *CachedStore) add(k string, le *pb.LinkEntry) {
+	if c.size <= 0 {
+		return
+	}
+	ce := &cacheEntry{key: k, le: le, expires: c.now().Add(c.ttl)}
+	if e, ok := c.entries[k]; ok {
+		e.Value = ce
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[k] = c.lru.PushFront(ce)
+	if c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an element from the cache. The caller must hold c.mu.
+func (c *CachedStore) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
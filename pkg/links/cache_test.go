@@ -0,0 +1,218 @@
+package links
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+func TestCachedStoreHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	backing := NewMemStore()
+	backing.Put(ctx, "foo", &pb.Link{Uri: "https://example.com"})
+	c := NewCachedStore(backing, 10, time.Minute)
+	hitsBefore, missesBefore := cacheHits.Value(), cacheMisses.Value()
+
+	for i := 0; i < 3; i++ {
+		le, err := c.Get(ctx, "foo")
+		if err != nil {
+			t.Fatalf("Get(foo) failed: %v", err)
+		}
+		if le.GetLink().GetUri() != "https://example.com" {
+			t.Fatalf("Get(foo) = %v, want https://example.com", le)
+		}
+	}
+	if got, want := c.Stats(), (CacheStats{Hits: 2, Misses: 1, Entries: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if hits, misses := cacheHits.Value()-hitsBefore, cacheMisses.Value()-missesBefore; hits != 2 || misses != 1 {
+		t.Errorf("Gets moved links_cache_hits_total by %v and links_cache_misses_total by %v, want 2 and 1", hits, misses)
+	}
+}
+
+func TestCachedStoreCachesMisses(t *testing.T) {
+	ctx := context.Background()
+	backing := NewMemStore()
+	c := NewCachedStore(backing, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if le, err := c.Get(ctx, "missing"); err != nil || le != nil {
+			t.Fatalf("Get(missing) = %v, %v; want nil, nil", le, err)
+		}
+	}
+	if got, want := c.Stats(), (CacheStats{Hits: 1, Misses: 1, Entries: 1}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// Creating the link through the cache must replace the cached miss.
+	if _, err := c.Put(ctx, "missing", &pb.Link{Uri: "https://example.com"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if le, err := c.Get(ctx, "missing"); err != nil || le == nil {
+		t.Fatalf("Get after Put = %v, %v; want the new entry", le, err)
+	}
+}
+
+func TestCachedStoreInvalidatesOnWrite(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedStore(NewMemStore(), 10, time.Minute)
+
+	c.Put(ctx, "foo", &pb.Link{Uri: "https://example.com/old"})
+	c.Get(ctx, "foo")
+	c.Put(ctx, "foo", &pb.Link{Uri: "https://example.com/new"})
+	le, err := c.Get(ctx, "foo")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got, want := le.GetLink().GetUri(), "https://example.com/new"; got != want {
+		t.Errorf("Get after update = %q, want %q", got, want)
+	}
+
+	c.Delete(ctx, "foo")
+	if le, err := c.Get(ctx, "foo"); err != nil || le != nil {
+		t.Errorf("Get after Delete = %v, %v; want nil, nil", le, err)
+	}
+}
+
+func TestCachedStoreExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	backing := NewMemStore()
+	backing.Put(ctx, "foo", &pb.Link{Uri: "https://example.com/old"})
+	c := NewCachedStore(backing, 10, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Get(ctx, "foo")
+	// A write that bypasses the cache is only picked up after the TTL.
+	backing.Put(ctx, "foo", &pb.Link{Uri: "https://example.com/new"})
+	if le, _ := c.Get(ctx, "foo"); le.GetLink().GetUri() != "https://example.com/old" {
+		t.Errorf("Get before expiry = %v, want the cached entry", le)
+	}
+	now = now.Add(time.Minute)
+	if le, _ := c.Get(ctx, "foo"); le.GetLink().GetUri() != "https://example.com/new" {
+		t.Errorf("Get after expiry = %v, want the updated entry", le)
+	}
+}
+
+func TestCachedStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedStore(NewMemStore(), 2, time.Minute)
+
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.Get(ctx, "a") // "b" is now the least recently used.
+	c.Get(ctx, "c")
+
+	if got := c.Stats().Entries; got != 2 {
+		t.Errorf("Entries = %d, want 2", got)
+	}
+	c.mu.Lock()
+	_, hasA := c.entries["a"]
+	_, hasB := c.entries["b"]
+	c.mu.Unlock()
+	if !hasA || hasB {
+		t.Errorf("after eviction: has a = %v, has b = %v; want true, false", hasA, hasB)
+	}
+}
+
+// BenchmarkRedirect compares redirect throughput against a SQLite store with
+// and without the cache in front of it, under concurrent load spread over a
+// handful of hot keys.
+func BenchmarkRedirect(b *testing.B) {
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	b.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	ctx := context.Background()
+	db, err := NewSQLiteStore(ctx, filepath.Join(b.TempDir(), "links.db"))
+	if err != nil {
+		b.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	const keys = 16
+	for i := 0; i < keys; i++ {
+		if _, err := db.Put(ctx, fmt.Sprint("key", i), &pb.Link{Uri: "https://example.com/{0}"}); err != nil {
+			b.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	for _, bc := range []struct {
+		name  string
+		store Store
+	}{
+		{"uncached", db},
+		{"cached", NewCachedStore(db, keys, time.Minute)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			h := (&server{store: bc.store}).redirect()
+			b.RunParallel(func(p *testing.PB) {
+				i := 0
+				for p.Next() {
+					rr := httptest.NewRecorder()
+					req := httptest.NewRequest("GET", fmt.Sprintf("/key%d/path", i%keys), nil)
+					h.ServeHTTP(rr, req)
+					if rr.Code != http.StatusFound {
+						b.Errorf("redirect returned %d, want %d", rr.Code, http.StatusFound)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func TestCachedStorePutAllInvalidates(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedStore(NewMemStore(), 10, time.Minute)
+
+	c.Get(ctx, "foo")
+	if _, _, err := c.PutAll(ctx, map[string]*pb.Link{"foo": {Uri: "https://example.com"}}); err != nil {
+		t.Fatalf("PutAll failed: %v", err)
+	}
+	if le, err := c.Get(ctx, "foo"); err != nil || le == nil {
+		t.Errorf("Get after PutAll = %v, %v; want the new entry", le, err)
+	}
+}
+
+func TestCachedStoreReplaceAllInvalidates(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedStore(NewMemStore(), 10, time.Minute)
+	c.Put(ctx, "stale", &pb.Link{Uri: "https://example.com/stale"})
+
+	c.Get(ctx, "stale")
+	if _, _, _, err := c.ReplaceAll(ctx, map[string]*pb.Link{"foo": {Uri: "https://example.com"}}); err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	if le, err := c.Get(ctx, "stale"); err != nil || le != nil {
+		t.Errorf("Get(stale) after ReplaceAll = %v, %v; want nil", le, err)
+	}
+	if st := c.Stats(); st.Entries != 1 {
+		t.Errorf("Stats().Entries after ReplaceAll = %d, want 1", st.Entries)
+	}
+}
+
+func TestCachedStoreRenameInvalidates(t *testing.T) {
+	ctx := context.Background()
+	c := NewCachedStore(NewMemStore(), 10, time.Minute)
+	c.Put(ctx, "old", &pb.Link{Uri: "https://example.com"})
+
+	c.Get(ctx, "old")
+	c.Get(ctx, "new")
+	if err := c.Rename(ctx, "old", "new", false, false); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if le, err := c.Get(ctx, "old"); err != nil || le != nil {
+		t.Errorf("Get(old) after Rename = %v, %v; want nil", le, err)
+	}
+	if le, err := c.Get(ctx, "new"); err != nil || le == nil {
+		t.Errorf("Get(new) after Rename = %v, %v; want the link", le, err)
+	}
+}
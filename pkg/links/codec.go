@@ -0,0 +1,132 @@
+package links
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// codec is a wire format for API request and response bodies.
+type codec struct {
+	contentType string
+	marshal     func(proto.Message) ([]byte, error)
+	unmarshal   func([]byte, proto.Message) error
+}
+
+var (
+	jsonCodec  = codec{"application/json", protojson.Marshal, protojson.Unmarshal}
+	protoCodec = codec{"application/x-protobuf", proto.Marshal, proto.Unmarshal}
+)
+
+// codecs maps the media types the API understands to their codecs.
+// "application/protobuf" is the newer registered name for binary protobuf;
+// both are accepted.
+var codecs = map[string]codec{
+	"application/json":       jsonCodec,
+	"application/x-protobuf": protoCodec,
+	"application/protobuf":   protoCodec,
+}
+
+// requestCodec picks the codec for r's body from its Content-Type. A body
+// without one is JSON, which is all the API used to speak.
+func requestCodec(r *http.Request) (codec, error) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return jsonCodec, nil
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return codec{}, fmt.Errorf("invalid Content-Type %q: %v", ct, err)
+	}
+	c, ok := codecs[mt]
+	if !ok {
+		return codec{}, fmt.Errorf("unsupported Content-Type %q; want application/json or application/x-protobuf", mt)
+	}
+	return c, nil
+}
+
+// responseCodec picks the codec for a response to r from its Accept header,
+// preferring higher q values and, among equals, the first listed. With no
+// Accept header, or a wildcard, the response is JSON.
+func responseCodec(r *http.Request) (codec, bool) {
+	accept := strings.Join(r.Header.Values("Accept"), ",")
+	if accept == "" {
+		return jsonCodec, true
+	}
+	var best codec
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		c, ok := codecs[mt]
+		if mt == "*/*" || mt == "application/*" {
+			c, ok = jsonCodec, true
+		}
+		if ok && q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// negotiateContent rejects requests that accept none of the formats the API
+// can respond in, before the handler does anything it couldn't report.
+func negotiateContent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := responseCodec(r); !ok {
+			writeError(w, r, http.StatusNotAcceptable,
+				"no acceptable content type; the API serves application/json and application/x-protobuf")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readBody decodes r's body into m in the format named by its Content-Type.
+// On failure it writes the error response (415 for an unsupported format)
+// and returns false.
+func readBody(w http.ResponseWriter, r *http.Request, m proto.Message) bool {
+	c, err := requestCodec(r)
+	if err != nil {
+		writeError(w, r, http.StatusUnsupportedMediaType, err.Error())
+		return false
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		internalError(w, r, err)
+		return false
+	}
+	if err := c.unmarshal(data, m); err != nil {
+		badRequest(w, r, "failed to unmarshal body: %v", err)
+		return false
+	}
+	return true
+}
+
+// writeBody encodes m in the format r accepts and writes it with status
+// code.
+func writeBody(w http.ResponseWriter, r *http.Request, code int, m proto.Message) {
+	c, _ := responseCodec(r)
+	data, err := c.marshal(m)
+	if err != nil {
+		internalError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", c.contentType)
+	w.WriteHeader(code)
+	w.Write(data)
+}
@@ -0,0 +1,141 @@
+package links
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	do := func(method, path string, body io.Reader, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, body)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		signRequest(t, priv, req)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+	binary := func(t *testing.T, m proto.Message) io.Reader {
+		t.Helper()
+		b, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.NewReader(b)
+	}
+	protoBody := http.Header{"Content-Type": {"application/x-protobuf"}}
+	acceptProto := http.Header{"Accept": {"application/x-protobuf"}}
+
+	if rr := do("PUT", "/api/links/foo", binary(t, &pb.Link{Uri: "http://foo"}), protoBody); rr.Code != http.StatusCreated {
+		t.Fatalf("binary PUT returned %d, want 201: %s", rr.Code, rr.Body)
+	}
+	// The registered name works too, with parameters.
+	if rr := do("PUT", "/api/links/bar", binary(t, &pb.Link{Uri: "http://bar"}),
+		http.Header{"Content-Type": {"application/protobuf; charset=binary"}}); rr.Code != http.StatusCreated {
+		t.Fatalf("PUT as application/protobuf returned %d, want 201: %s", rr.Code, rr.Body)
+	}
+
+	t.Run("binary get", func(t *testing.T) {
+		rr := do("GET", "/api/links/foo", nil, acceptProto)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("GET returned %d, want 200", rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+			t.Errorf("Content-Type = %q, want application/x-protobuf", ct)
+		}
+		got := &pb.Link{}
+		if err := proto.Unmarshal(rr.Body.Bytes(), got); err != nil {
+			t.Fatalf("response isn't binary protobuf: %v", err)
+		}
+		if got.GetUri() != "http://foo" {
+			t.Errorf("GET uri = %q, want http://foo", got.GetUri())
+		}
+	})
+
+	t.Run("binary list", func(t *testing.T) {
+		rr := do("GET", "/api/links", nil, acceptProto)
+		got := &pb.Links{}
+		if err := proto.Unmarshal(rr.Body.Bytes(), got); err != nil {
+			t.Fatalf("response isn't binary protobuf: %v", err)
+		}
+		if len(got.GetLinks()) != 2 {
+			t.Errorf("list returned %d links, want 2", len(got.GetLinks()))
+		}
+	})
+
+	t.Run("binary dry run", func(t *testing.T) {
+		lpb := &pb.Links{Links: map[string]*pb.Link{"baz": {Uri: "http://baz"}}}
+		header := http.Header{"Content-Type": protoBody["Content-Type"], "Accept": acceptProto["Accept"]}
+		rr := do("POST", "/api/links?dry_run=true", binary(t, lpb), header)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("dry run returned %d, want 200: %s", rr.Code, rr.Body)
+		}
+		diff := &pb.LinksDiff{}
+		if err := proto.Unmarshal(rr.Body.Bytes(), diff); err != nil {
+			t.Fatalf("response isn't binary protobuf: %v", err)
+		}
+		if len(diff.GetAdded()) != 1 || diff.GetAdded()[0].GetKey() != "baz" {
+			t.Errorf("dry run added = %v, want baz", diff.GetAdded())
+		}
+	})
+
+	t.Run("json by default", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "application/*", "text/html, application/json;q=0.5"} {
+			header := http.Header{}
+			if accept != "" {
+				header.Set("Accept", accept)
+			}
+			rr := do("GET", "/api/links/foo", nil, header)
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Accept %q: Content-Type = %q, want application/json", accept, ct)
+			}
+		}
+	})
+
+	t.Run("q values", func(t *testing.T) {
+		rr := do("GET", "/api/links/foo", nil, http.Header{"Accept": {"application/json;q=0.5, application/x-protobuf"}})
+		if ct := rr.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+			t.Errorf("Content-Type = %q, want the preferred application/x-protobuf", ct)
+		}
+		rr = do("GET", "/api/links/foo", nil, http.Header{"Accept": {"application/json, application/x-protobuf;q=0"}})
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		for _, ct := range []string{"text/plain", "application/x-www-form-urlencoded"} {
+			rr := do("PUT", "/api/links/foo", strings.NewReader("uri=http://nope"), http.Header{"Content-Type": {ct}})
+			if rr.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("PUT as %s returned %d, want 415", ct, rr.Code)
+			}
+		}
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		rr := do("GET", "/api/links/foo", nil, http.Header{"Accept": {"application/xml"}})
+		if rr.Code != http.StatusNotAcceptable {
+			t.Errorf("GET accepting only XML returned %d, want 406", rr.Code)
+		}
+	})
+
+	t.Run("binary body sent as json", func(t *testing.T) {
+		rr := do("PUT", "/api/links/foo", binary(t, &pb.Link{Uri: "http://foo"}), http.Header{"Content-Type": {"application/json"}})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("binary body labelled JSON returned %d, want 400", rr.Code)
+		}
+	})
+}
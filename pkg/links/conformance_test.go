@@ -0,0 +1,28 @@
+package links_test
+
+import (
+	"os"
+	"testing"
+
+	"jdtw.dev/links/pkg/links"
+	"jdtw.dev/links/pkg/storetest"
+)
+
+func TestMemStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(*testing.T) links.Store { return links.NewMemStore() })
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) links.Store { return links.NewTestSQLiteStore(t) })
+}
+
+func TestFileStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) links.Store { return links.NewTestFileStore(t) })
+}
+
+func TestPostgresStoreConformance(t *testing.T) {
+	if os.Getenv("TEST_DATABASE_URL") == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	storetest.RunConformance(t, func(t *testing.T) links.Store { return links.NewTestPostgresStore(t) })
+}
@@ -0,0 +1,116 @@
+package links
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+// keyAlphabet is what generated keys are made of: lowercase letters and
+// digits, minus the ones easily confused with each other when read aloud or
+// off a slide (0/o, 1/l/i). It has no hyphens, so generated keys are
+// already normalized.
+const keyAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+const (
+	// minKeyLen is the length of the first keys tried. 31^4 is close to a
+	// million keys, which is plenty for a personal link shortener.
+	minKeyLen = 4
+	// maxKeyLen bounds how far the length grows when keys keep colliding.
+	maxKeyLen = 12
+	// keyAttempts is how many keys of each length are tried before moving
+	// on to a longer one.
+	keyAttempts = 8
+)
+
+// errKeySpaceExhausted is returned if no free key can be found at all, which
+// would take a store full of keys of every length up to maxKeyLen.
+var errKeySpaceExhausted = errors.New("no free key found")
+
+// generateKey returns a random key of length n drawn from keyAlphabet.
+func generateKey(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		// 256 isn't a multiple of len(keyAlphabet), so this is slightly
+		// biased; that doesn't matter for keys that only need to be
+		// unlikely to collide.
+		b[i] = keyAlphabet[int(b[i])%len(keyAlphabet)]
+	}
+	return string(b)
+}
+
+// create stores a link under a key that isn't taken yet. The request body is
+// a CreateLinkRequest. If it names a key, the link is created under that key
+// or, if the key is taken, not at all (409). Otherwise the server picks a
+// short random key. Either way, the response is a CreateLinkResponse
+// holding the key used.
+func (s *server) create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := new(pb.CreateLinkRequest)
+		if !readBody(w, r, req) {
+			return
+		}
+		lpb := req.GetLink()
+
+		var key string
+		if req.GetKey() != "" {
+			key = normalizeKey(req.GetKey())
+			if err := validateLink(key, lpb); err != nil {
+				badRequest(w, r, "%v", err)
+				return
+			}
+			created, err := s.store.CompareAndSwap(r.Context(), key, nil, lpb)
+			if err != nil {
+				storeError(w, r, err)
+				return
+			}
+			if !created {
+				alreadyExists(w, r, key)
+				return
+			}
+		} else {
+			// Validate once up front, with a key that can't be reserved,
+			// so a bad URI isn't retried for every candidate key.
+			if err := validateLink(generateKey(minKeyLen), lpb); err != nil {
+				badRequest(w, r, "%v", err)
+				return
+			}
+			var err error
+			if key, err = s.createWithGeneratedKey(r, lpb); err != nil {
+				storeError(w, r, err)
+				return
+			}
+		}
+
+		w.Header().Set("Location", linkPath(r.Context(), key))
+		w.Header().Set("ETag", etag(lpb))
+		writeBody(w, r, http.StatusCreated, &pb.CreateLinkResponse{Key: key})
+		logger(r.Context()).Info("link added", "key", key, "target", lpb.GetUri(), "generated", req.GetKey() == "")
+	}
+}
+
+// createWithGeneratedKey stores l under a fresh random key and returns the
+// key. Each candidate is claimed with a create-only CompareAndSwap, so two
+// requests racing for the same key can't both win it. Keys start short and
+// grow whenever several in a row turn out to be taken.
+func (s *server) createWithGeneratedKey(r *http.Request, l *pb.Link) (string, error) {
+	for n := minKeyLen; n <= maxKeyLen; n++ {
+		for range keyAttempts {
+			key := generateKey(n)
+			if reservedKeys[key] {
+				continue
+			}
+			created, err := s.store.CompareAndSwap(r.Context(), key, nil, l)
+			if err != nil {
+				return "", err
+			}
+			if created {
+				return key, nil
+			}
+		}
+	}
+	return "", errKeySpaceExhausted
+}
@@ -0,0 +1,116 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+	"jdtw.dev/token"
+)
+
+func postCreate(t *testing.T, srv http.Handler, priv *token.SigningKey, key, uri string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/links/new", marshal(t, &pb.CreateLinkRequest{
+		Link: &pb.Link{Uri: uri},
+		Key:  key,
+	}))
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		return rr, ""
+	}
+	res := new(pb.CreateLinkResponse)
+	unmarshal(t, rr.Body, res)
+	return rr, res.GetKey()
+}
+
+func TestCreateGeneratesKey(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	srv := NewHandler(store, keyset, 0)
+
+	seen := make(map[string]bool)
+	for range 20 {
+		rr, key := postCreate(t, srv, priv, "", "https://example.com/{0}")
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("POST /api/links/new returned %d, want 201", rr.Code)
+		}
+		if len(key) != minKeyLen || strings.Trim(key, keyAlphabet) != "" {
+			t.Errorf("generated key %q, want %d characters from %q", key, minKeyLen, keyAlphabet)
+		}
+		if seen[key] {
+			t.Errorf("generated key %q twice", key)
+		}
+		seen[key] = true
+		if loc := rr.Header().Get("Location"); loc != "/"+key {
+			t.Errorf("Location = %q, want /%s", loc, key)
+		}
+		le, err := store.Get(context.Background(), key)
+		if err != nil || le.GetLink().GetUri() != "https://example.com/{0}" || le.GetRequiredPaths() != 1 {
+			t.Errorf("Get(%s) = %v, %v; want the created link", key, le, err)
+		}
+	}
+}
+
+func TestCreateWithRequestedKey(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	srv := NewHandler(store, keyset, 0)
+
+	if rr, key := postCreate(t, srv, priv, "my-link", "https://example.com/first"); rr.Code != http.StatusCreated || key != "mylink" {
+		t.Fatalf("POST with key my-link = %d %q, want 201 mylink", rr.Code, key)
+	}
+	// Taken, even though spelled differently.
+	if rr, _ := postCreate(t, srv, priv, "mylink", "https://example.com/second"); rr.Code != http.StatusConflict {
+		t.Errorf("POST with a taken key returned %d, want 409", rr.Code)
+	}
+	if le, _ := store.Get(context.Background(), "mylink"); le.GetLink().GetUri() != "https://example.com/first" {
+		t.Errorf("a conflicting create overwrote the link: %v", le)
+	}
+}
+
+func TestCreateRejectsInvalidRequests(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+	for _, tc := range []struct{ key, uri string }{
+		{"", ""},
+		{"", "no-scheme"},
+		{"qr", "https://example.com"},
+		{"health-z", "https://example.com"},
+	} {
+		if rr, _ := postCreate(t, srv, priv, tc.key, tc.uri); rr.Code != http.StatusBadRequest {
+			t.Errorf("POST key=%q uri=%q returned %d, want 400", tc.key, tc.uri, rr.Code)
+		}
+	}
+}
+
+// crowdedStore reports every key shorter than free as taken.
+type crowdedStore struct {
+	*MemStore
+	free int
+}
+
+func (s *crowdedStore) CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error) {
+	if len(k) < s.free {
+		return false, nil
+	}
+	return s.MemStore.CompareAndSwap(ctx, k, old, new)
+}
+
+func TestCreateGrowsKeysOnCollision(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(&crowdedStore{NewMemStore(), minKeyLen + 2}, keyset, 0)
+	if rr, key := postCreate(t, srv, priv, "", "https://example.com"); rr.Code != http.StatusCreated || len(key) != minKeyLen+2 {
+		t.Errorf("POST into a crowded store = %d %q, want 201 and a %d character key", rr.Code, key, minKeyLen+2)
+	}
+
+	srv = NewHandler(&crowdedStore{NewMemStore(), maxKeyLen + 1}, keyset, 0)
+	if rr, _ := postCreate(t, srv, priv, "", "https://example.com"); rr.Code != http.StatusInternalServerError {
+		t.Errorf("POST into a full store returned %d, want 500", rr.Code)
+	}
+}
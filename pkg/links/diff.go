@@ -0,0 +1,27 @@
+package links
+
+import (
+	"context"
+
+	"jdtw.dev/links/pkg/diff"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// diffLinks describes what importing links into store would do. With
+// replace set, keys in the store that links does not mention are reported as
+// deleted; otherwise they are left out, since an additive import doesn't
+// touch them.
+func diffLinks(ctx context.Context, store Store, links map[string]*pb.Link, replace bool) (*pb.LinksDiff, error) {
+	current := make(map[string]*pb.Link)
+	if err := store.Visit(ctx, func(k string, le *pb.LinkEntry) {
+		current[k] = le.GetLink()
+	}); err != nil {
+		return nil, err
+	}
+
+	var deleted func(*pb.Link) bool
+	if replace {
+		deleted = func(*pb.Link) bool { return true }
+	}
+	return diff.Links(current, links, deleted), nil
+}
@@ -0,0 +1,141 @@
+package links
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// apiError is the JSON body of every error response, whatever format the
+// request asked for, so that clients can always tell what went wrong.
+type apiError struct {
+	// Code names the kind of error; see errorCode.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RequestID matches the request_id in the server's log lines for the
+	// request.
+	RequestID string `json:"request_id,omitempty"`
+	// Problems lists what was wrong with each rejected link, for requests
+	// that carry many.
+	Problems []problem `json:"problems,omitempty"`
+}
+
+// problem is something wrong with one link in a bulk request.
+type problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// codeReadOnly is the code of the error a write gets while the server is
+// read-only. It is a 503, but unlike other 503s, retrying won't help until
+// someone turns read-only mode off.
+const codeReadOnly = "read_only"
+
+// errorCode names the kind of error an HTTP status stands for. The names
+// are stable, unlike messages, so clients can switch on them.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_argument"
+	case http.StatusUnauthorized:
+		return "unauthenticated"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusNotAcceptable:
+		return "not_acceptable"
+	case http.StatusConflict:
+		return "already_exists"
+	case http.StatusPreconditionFailed:
+		return "failed_precondition"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	default:
+		return "internal"
+	}
+}
+
+// writeError writes an apiError with the given status and message.
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string, problems ...problem) {
+	writeAPIError(w, r, status, &apiError{
+		Code:     errorCode(status),
+		Message:  msg,
+		Problems: problems,
+	})
+}
+
+// writeAPIError writes e with the given status, filling in the request ID.
+func writeAPIError(w http.ResponseWriter, r *http.Request, status int, e *apiError) {
+	h := w.Header()
+	// Drop headers set for the success response that never came.
+	h.Del("ETag")
+	h.Del("Location")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	e.RequestID = middleware.GetReqID(r.Context())
+	json.NewEncoder(w).Encode(e)
+}
+
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	logger(r.Context()).Error("internal error", "error", err)
+	writeError(w, r, http.StatusInternalServerError, err.Error())
+}
+
+// storeError reports a failed write to the store: a 503 if the server is
+// read-only, which needs telling apart from a 5xx worth retrying, and an
+// internal error otherwise.
+func storeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrReadOnly) {
+		writeAPIError(w, r, http.StatusServiceUnavailable, &apiError{
+			Code:    codeReadOnly,
+			Message: "links are read-only for now; writes are refused until read-only mode is turned off",
+		})
+		return
+	}
+	internalError(w, r, err)
+}
+
+func badRequest(w http.ResponseWriter, r *http.Request, format string, a ...interface{}) {
+	writeError(w, r, http.StatusBadRequest, fmt.Sprintf(format, a...))
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, key string) {
+	writeError(w, r, http.StatusNotFound, fmt.Sprintf("link %q not found", key))
+}
+
+func alreadyExists(w http.ResponseWriter, r *http.Request, key string) {
+	writeError(w, r, http.StatusConflict, fmt.Sprintf("link %q already exists", key))
+}
+
+// invalidLinksError reports every link a bulk request was rejected for, so
+// that they can all be fixed in one go.
+type invalidLinksError struct {
+	total    int
+	problems []problem
+}
+
+func (e *invalidLinksError) summary() string {
+	return fmt.Sprintf("rejected %d of %d links", len(e.problems), e.total)
+}
+
+func (e *invalidLinksError) Error() string {
+	lines := make([]string, len(e.problems))
+	for i, p := range e.problems {
+		lines[i] = fmt.Sprintf("%q: %s", p.Key, p.Message)
+	}
+	return e.summary() + ":\n" + strings.Join(lines, "\n")
+}
+
+func (e *invalidLinksError) sort() {
+	sort.Slice(e.problems, func(i, j int) bool {
+		if e.problems[i].Key != e.problems[j].Key {
+			return e.problems[i].Key < e.problems[j].Key
+		}
+		return e.problems[i].Message < e.problems[j].Message
+	})
+}
@@ -0,0 +1,109 @@
+package links
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+)
+
+func decodeError(t *testing.T, resp *http.Response) *apiError {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("error Content-Type = %q, want application/json", ct)
+	}
+	e := new(apiError)
+	if err := json.NewDecoder(resp.Body).Decode(e); err != nil {
+		t.Fatalf("error body isn't JSON: %v", err)
+	}
+	if e.RequestID == "" {
+		t.Error("error has no request ID")
+	}
+	return e
+}
+
+func TestErrorResponses(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	do := func(method, path, body string, sign bool) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body == "" {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		if sign {
+			signRequest(t, priv, req)
+		}
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		unsigned bool
+		status   int
+		code     string
+	}{
+		{"unauthenticated", "GET", "/api/links", "", true, http.StatusUnauthorized, "unauthenticated"},
+		{"not found", "GET", "/api/links/missing", "", false, http.StatusNotFound, "not_found"},
+		{"bad link", "PUT", "/api/links/foo", `{"uri":"no-scheme"}`, false, http.StatusBadRequest, "invalid_argument"},
+		{"bad body", "PUT", "/api/links/foo", `{`, false, http.StatusBadRequest, "invalid_argument"},
+		{"delete missing", "DELETE", "/api/links/missing", "", false, http.StatusNotFound, "not_found"},
+		{"rename missing", "POST", "/api/links/missing/rename?to=new", "", false, http.StatusNotFound, "not_found"},
+		{"redirect missing", "GET", "/missing", "", true, http.StatusNotFound, "not_found"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := do(tc.method, tc.path, tc.body, !tc.unsigned)
+			if resp.StatusCode != tc.status {
+				t.Fatalf("%s %s returned %d, want %d", tc.method, tc.path, resp.StatusCode, tc.status)
+			}
+			e := decodeError(t, resp)
+			if e.Code != tc.code {
+				t.Errorf("code = %q, want %q", e.Code, tc.code)
+			}
+			if e.Message == "" {
+				t.Error("error has no message")
+			}
+		})
+	}
+}
+
+func TestBulkPutReportsEveryProblem(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	resp := postLinks(t, srv, priv, map[string]string{
+		"good":     "https://example.com",
+		"noscheme": "example.com",
+		"qr":       "https://example.com/qr",
+		"my-link":  "https://example.com/1",
+		"mylink":   "https://example.com/2",
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("POST returned %d, want 400", resp.StatusCode)
+	}
+	e := decodeError(t, resp)
+	if e.Message != "rejected 3 of 5 links" {
+		t.Errorf("message = %q, want a summary", e.Message)
+	}
+	var keys []string
+	for _, p := range e.Problems {
+		keys = append(keys, p.Key)
+		if p.Message == "" {
+			t.Errorf("problem with %q has no message", p.Key)
+		}
+	}
+	// Either hyphenated spelling may be the one reported as colliding.
+	if got := strings.Join(keys, ","); got != "my-link,noscheme,qr" && got != "mylink,noscheme,qr" {
+		t.Errorf("problems are for %v, want noscheme, qr and one of my-link/mylink, sorted", keys)
+	}
+}
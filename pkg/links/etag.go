@@ -0,0 +1,77 @@
+package links
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// etag identifies a revision of a link by hashing it, so that stores don't
+// need to track revisions themselves. Two writes of an identical link share
+// an ETag, which is harmless: a client holding it is not out of date.
+func etag(l *pb.Link) string {
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(l)
+	sum := sha256.Sum256(b)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`
+}
+
+// conditional reports whether r carries preconditions that a write must
+// check before it goes ahead.
+func conditional(r *http.Request) bool {
+	return r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != ""
+}
+
+// preconditionsMet evaluates r's If-Match and If-None-Match headers against
+// cur, the link currently stored, or nil if there is none. "If-None-Match: *"
+// thus makes a write create-only, and "If-Match: <etag>" makes it an update
+// of that exact revision.
+func preconditionsMet(r *http.Request, cur *pb.Link) bool {
+	if im := r.Header.Get("If-Match"); im != "" {
+		if cur == nil || !matchETag(im, etag(cur)) {
+			return false
+		}
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if cur != nil && matchETag(inm, etag(cur)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchETag reports whether header, a comma-separated list of ETags or "*",
+// matches tag.
+func matchETag(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if t = strings.TrimSpace(t); t == "*" || t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// swapIfMatch applies a conditional write of new (nil to delete) to k. It
+// reads the current link, checks r's preconditions against it, and then
+// swaps only if the link is still the one checked, so a write racing this
+// one can't slip in between. It reports whether the write happened and, if
+// so, whether it created k.
+func (s *server) swapIfMatch(r *http.Request, k string, new *pb.Link) (swapped, created bool, err error) {
+	le, err := s.store.Get(r.Context(), k)
+	if err != nil {
+		return false, false, err
+	}
+	cur := le.GetLink()
+	if !preconditionsMet(r, cur) {
+		return false, false, nil
+	}
+	swapped, err = s.store.CompareAndSwap(r.Context(), k, cur, new)
+	return swapped, cur == nil, err
+}
+
+func preconditionFailed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusPreconditionFailed, "link does not match the request's preconditions")
+}
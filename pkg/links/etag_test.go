@@ -0,0 +1,77 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	do := func(method, uri string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		path := "/api/links/foo"
+		req := httptest.NewRequest(method, path, nil)
+		if uri != "" {
+			req = httptest.NewRequest(method, path, marshalLink(t, uri))
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		signRequest(t, priv, req)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr
+	}
+	ifMatch := func(etag string) http.Header { return http.Header{"If-Match": {etag}} }
+	createOnly := http.Header{"If-None-Match": {"*"}}
+
+	if rr := do("PUT", "http://v1", ifMatch("*")); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT If-Match: * of a missing link returned %d, want 412", rr.Code)
+	}
+	rr := do("PUT", "http://v1", createOnly)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create-only PUT returned %d, want 201", rr.Code)
+	}
+	v1 := rr.Header().Get("ETag")
+	if v1 == "" {
+		t.Fatal("PUT returned no ETag")
+	}
+	if rr := do("PUT", "http://v1", createOnly); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("create-only PUT of an existing link returned %d, want 412", rr.Code)
+	}
+	if got := do("GET", "", nil).Header().Get("ETag"); got != v1 {
+		t.Errorf("GET ETag = %q, want %q from the PUT", got, v1)
+	}
+
+	rr = do("PUT", "http://v2", ifMatch(v1))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("PUT If-Match current ETag returned %d, want 204", rr.Code)
+	}
+	v2 := rr.Header().Get("ETag")
+	if v2 == v1 {
+		t.Errorf("ETag didn't change when the link did")
+	}
+
+	// The second editor still holds v1.
+	if rr := do("PUT", "http://clobber", ifMatch(v1)); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT If-Match stale ETag returned %d, want 412", rr.Code)
+	}
+	if rr := do("DELETE", "", ifMatch(v1)); rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("DELETE If-Match stale ETag returned %d, want 412", rr.Code)
+	}
+	if rr := do("GET", "", nil); rr.Header().Get("ETag") != v2 {
+		t.Errorf("a rejected write changed the link: ETag = %q, want %q", rr.Header().Get("ETag"), v2)
+	}
+
+	if rr := do("DELETE", "", ifMatch(`"other", `+v2)); rr.Code != http.StatusNoContent {
+		t.Errorf("DELETE If-Match list with current ETag returned %d, want 204", rr.Code)
+	}
+	if rr := do("GET", "", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("GET after conditional DELETE returned %d, want 404", rr.Code)
+	}
+}
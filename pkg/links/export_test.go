@@ -0,0 +1,15 @@
+package links
+
+import "testing"
+
+// Test helpers for the external links_test package, which can run the
+// storetest suite without an import cycle.
+var (
+	NewTestSQLiteStore   = newTestSQLiteStore
+	NewTestPostgresStore = newTestPostgresStore
+)
+
+func NewTestFileStore(t *testing.T) *FileStore {
+	s, _ := newTestFileStore(t, "links.json")
+	return s
+}
@@ -0,0 +1,381 @@
+package links
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// fileStorePollInterval is how often a FileStore checks whether its file
+// was changed by something other than the store.
+const fileStorePollInterval = time.Second
+
+// FileStore is a Store backed by a file holding a Links proto, in the JSON
+// form that client --export writes or, if the file name ends in .yaml or
+// .yml, the same structure in YAML. It is meant for small deployments and
+// local development, where a database is more than the links need.
+//
+// The links are served from memory. Every write replaces the whole file,
+// atomically, by writing a new one next to it and renaming it into place.
+// The file can also be edited by hand: the store notices within a
+// second or so and reloads it. Keys are normalized and links validated as
+// for a bulk import. If the edited file can't be parsed or holds a link
+// that would be rejected, the store keeps serving what it had and Ping
+// reports the error until the file is fixed.
+type FileStore struct {
+	path string
+	yaml bool
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// mu serializes writes, and guards the fields below. Readers only
+	// hold it long enough to get links.
+	mu    sync.RWMutex
+	links *MemStore
+	// stat is the file as last loaded or written, to tell when it has
+	// changed since.
+	stat    os.FileInfo
+	loadErr error
+}
+
+var (
+	_ Store  = &FileStore{}
+	_ Pinger = &FileStore{}
+)
+
+// NewFileStore loads the links in the file at path, creating the file if it
+// doesn't exist yet, and starts watching it for changes. Close stops the
+// watching.
+func NewFileStore(ctx context.Context, path string) (*FileStore, error) {
+	return newFileStore(ctx, path, fileStorePollInterval)
+}
+
+func newFileStore(ctx context.Context, path string, poll time.Duration) (*FileStore, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	s := &FileStore{
+		path:  path,
+		yaml:  ext == ".yaml" || ext == ".yml",
+		done:  make(chan struct{}),
+		links: NewMemStore(),
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := s.write(s.links); err != nil {
+			return nil, fmt.Errorf("creating %s failed: %w", path, err)
+		}
+	} else if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.watch(poll)
+	return s, nil
+}
+
+// Close stops watching the file.
+func (s *FileStore) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *FileStore) watch(poll time.Duration) {
+	defer s.wg.Done()
+	t := time.NewTicker(poll)
+	defer t.Stop()
+	// lastErr keeps a file that stays broken from being logged every poll.
+	var lastErr string
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+			s.mu.Lock()
+			err := s.refreshLocked()
+			s.mu.Unlock()
+			switch {
+			case err != nil && err.Error() != lastErr:
+				logger(context.Background()).Warn("reloading links file failed", "path", s.path, "error", err)
+				lastErr = err.Error()
+			case err == nil && lastErr != "":
+				logger(context.Background()).Info("reloaded links file", "path", s.path)
+				lastErr = ""
+			}
+		}
+	}
+}
+
+// refreshLocked reloads the file if it changed since it was last loaded or
+// written. The caller must hold the write lock.
+func (s *FileStore) refreshLocked() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		s.loadErr = err
+		return err
+	}
+	if s.stat != nil && fi.ModTime().Equal(s.stat.ModTime()) && fi.Size() == s.stat.Size() {
+		return nil
+	}
+	return s.reloadLocked()
+}
+
+func (s *FileStore) reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reloadLocked()
+}
+
+func (s *FileStore) reloadLocked() error {
+	fi, err := os.Stat(s.path)
+	if err == nil {
+		var links *MemStore
+		if links, err = s.read(); err == nil {
+			s.links, s.stat, s.loadErr = links, fi, nil
+			return nil
+		}
+	}
+	// Remember what failed to load, so as not to retry until it changes
+	// again.
+	s.stat, s.loadErr = fi, fmt.Errorf("loading %s failed: %w", s.path, err)
+	return s.loadErr
+}
+
+// read parses the file.
+func (s *FileStore) read() (*MemStore, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if s.yaml {
+		// protojson knows how to read a Links proto, and YAML is a
+		// superset of JSON, so go through that.
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			v = map[string]any{}
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	lpb := new(pb.Links)
+	if err := protojson.Unmarshal(data, lpb); err != nil {
+		return nil, err
+	}
+	// A hand edit gets the same checks as a bulk import, so that a key
+	// like "my-link" is stored as "mylink", where redirects look for it,
+	// and a reserved key or a link without a scheme is rejected.
+	normalized, err := normalizeLinks(lpb.GetLinks())
+	if err != nil {
+		return nil, err
+	}
+	links := NewMemStore()
+	for k, l := range normalized {
+		links.entries[k] = &pb.LinkEntry{Link: l, RequiredPaths: requiredPaths(l)}
+	}
+	return links, nil
+}
+
+// write replaces the file with links, by way of a temporary file renamed
+// over it, so that a reader never sees a partly written file. The caller
+// must hold the write lock.
+func (s *FileStore) write(links *MemStore) error {
+	lpb := &pb.Links{Links: make(map[string]*pb.Link, len(links.entries))}
+	for k, le := range links.entries {
+		lpb.Links[k] = le.GetLink()
+	}
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(lpb)
+	if err != nil {
+		return err
+	}
+	if s.yaml {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+	}
+
+	dir, base := filepath.Split(s.path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file readable by its owner only, which suits a
+	// new file; an existing one keeps its permissions.
+	if s.stat != nil {
+		if err := os.Chmod(f.Name(), s.stat.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return err
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.links, s.stat, s.loadErr = links, fi, nil
+	return nil
+}
+
+// update applies fn to a copy of the links and, if fn reports a change,
+// writes the copy to the file before serving from it. A write that fails
+// leaves both the file and the served links as they were.
+func (s *FileStore) update(ctx context.Context, fn func(links *MemStore) (changed bool, err error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Pick up a hand edit made since the last poll, rather than write
+	// over it; and if the edit can't be loaded, leave it for its author to
+	// fix.
+	if err := s.refreshLocked(); err != nil {
+		return err
+	}
+	if s.loadErr != nil {
+		return s.loadErr
+	}
+	links := s.links.clone()
+	changed, err := fn(links)
+	if err != nil || !changed {
+		return err
+	}
+	if err := s.write(links); err != nil {
+		return fmt.Errorf("writing %s failed: %w", s.path, err)
+	}
+	return nil
+}
+
+// current returns the links being served. A MemStore is safe to read
+// concurrently, and update replaces rather than modifies it, so callers
+// needn't hold the lock while they use it.
+func (s *FileStore) current() *MemStore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.links
+}
+
+// clone copies the store. Entries are never modified in place, so they can
+// be shared.
+func (s *MemStore) clone() *MemStore {
+	s.RLock()
+	defer s.RUnlock()
+	c := NewMemStore()
+	for k, le := range s.entries {
+		c.entries[k] = le
+	}
+	return c
+}
+
+// Ping reports whether the file could be loaded the last time it changed.
+func (s *FileStore) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadErr
+}
+
+func (s *FileStore) Get(ctx context.Context, k string) (*pb.LinkEntry, error) {
+	return s.current().Get(ctx, k)
+}
+
+func (s *FileStore) Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error {
+	return s.current().Visit(ctx, visit)
+}
+
+func (s *FileStore) Put(ctx context.Context, k string, l *pb.Link) (bool, error) {
+	var created bool
+	err := s.update(ctx, func(links *MemStore) (bool, error) {
+		var err error
+		created, err = links.Put(ctx, k, l)
+		return err == nil, err
+	})
+	return created, err
+}
+
+func (s *FileStore) PutAll(ctx context.Context, m map[string]*pb.Link) (int, int, error) {
+	var created, updated int
+	err := s.update(ctx, func(links *MemStore) (bool, error) {
+		var err error
+		created, updated, err = links.PutAll(ctx, m)
+		return err == nil, err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return created, updated, nil
+}
+
+func (s *FileStore) ReplaceAll(ctx context.Context, m map[string]*pb.Link) (int, int, int, error) {
+	var created, updated, deleted int
+	err := s.update(ctx, func(links *MemStore) (bool, error) {
+		var err error
+		created, updated, deleted, err = links.ReplaceAll(ctx, m)
+		return err == nil, err
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return created, updated, deleted, nil
+}
+
+func (s *FileStore) CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error) {
+	var swapped bool
+	err := s.update(ctx, func(links *MemStore) (bool, error) {
+		var err error
+		swapped, err = links.CompareAndSwap(ctx, k, old, new)
+		return swapped, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, k string) (bool, error) {
+	var deleted bool
+	err := s.update(ctx, func(links *MemStore) (bool, error) {
+		var err error
+		deleted, err = links.Delete(ctx, k)
+		return deleted, err
+	})
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+func (s *FileStore) Rename(ctx context.Context, from, to string, overwrite, alias bool) error {
+	return s.update(ctx, func(links *MemStore) (bool, error) {
+		err := links.Rename(ctx, from, to, overwrite, alias)
+		return err == nil, err
+	})
+}
@@ -0,0 +1,221 @@
+package links
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// newTestFileStore opens a store backed by a file in the test's temp
+// directory, polling it far more often than a real one would.
+func newTestFileStore(t *testing.T, name string) (*FileStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	s, err := newFileStore(context.Background(), path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("newFileStore failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+// eventually waits for cond to hold, failing the test if it doesn't within
+// a few seconds.
+func eventually(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting until %s", desc)
+}
+
+func TestFileStoreLoadsExport(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(&pb.Links{Links: map[string]*pb.Link{
+		"foo": {Uri: "http://example.com/{0}", Managed: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewFileStore(ctx, path)
+	if err != nil {
+		t.Fatalf("NewFileStore failed: %v", err)
+	}
+	defer s.Close()
+	le, err := s.Get(ctx, "foo")
+	if err != nil || le.GetLink().GetUri() != "http://example.com/{0}" || !le.GetLink().GetManaged() || le.GetRequiredPaths() != 1 {
+		t.Errorf("Get(foo) = %v, %v; want the exported link", le, err)
+	}
+
+	// Writing keeps the file's permissions, and leaves no temporary file
+	// behind.
+	if _, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com/bar"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("after Put the file is %v, %v; want mode 0644", fi, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("after Put the directory holds %d files, want just the links file", len(entries))
+	}
+}
+
+func TestFileStorePersists(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range []string{"links.json", "links.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			s, path := newTestFileStore(t, name)
+			if _, err := s.Put(ctx, "foo", &pb.Link{Uri: "http://example.com/{0}", Managed: true}); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if _, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com/bar"}); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if _, err := s.Delete(ctx, "bar"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+
+			reopened, err := NewFileStore(ctx, path)
+			if err != nil {
+				t.Fatalf("reopening store failed: %v", err)
+			}
+			defer reopened.Close()
+			got := map[string]*pb.LinkEntry{}
+			reopened.Visit(ctx, func(k string, le *pb.LinkEntry) { got[k] = le })
+			if le := got["foo"]; len(got) != 1 || le.GetLink().GetUri() != "http://example.com/{0}" || !le.GetLink().GetManaged() {
+				t.Errorf("reopened store holds %v, want just foo", got)
+			}
+		})
+	}
+}
+
+func TestFileStoreReloadsHandEdits(t *testing.T) {
+	ctx := context.Background()
+	s, path := newTestFileStore(t, "links.yaml")
+	if _, err := s.Put(ctx, "foo", &pb.Link{Uri: "http://example.com/foo"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	edit := "links:\n  foo:\n    uri: http://example.com/edited\n  bar:\n    uri: http://example.com/bar/{0}\n"
+	if err := os.WriteFile(path, []byte(edit), 0600); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the edit is loaded", func() bool {
+		le, _ := s.Get(ctx, "foo")
+		return le.GetLink().GetUri() == "http://example.com/edited"
+	})
+	if le, err := s.Get(ctx, "bar"); err != nil || le.GetRequiredPaths() != 1 {
+		t.Errorf("Get(bar) = %v, %v; want the added link", le, err)
+	}
+}
+
+// A write made before the poll notices a hand edit must not undo the edit.
+func TestFileStoreWriteKeepsHandEdit(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.json")
+	// Effectively never poll.
+	s, err := newFileStore(ctx, path, time.Hour)
+	if err != nil {
+		t.Fatalf("newFileStore failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := os.WriteFile(path, []byte(`{"links": {"edited": {"uri": "http://example.com/edited"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(ctx, "put", &pb.Link{Uri: "http://example.com/put"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	for _, k := range []string{"edited", "put"} {
+		if le, err := s.Get(ctx, k); err != nil || le == nil {
+			t.Errorf("Get(%s) = %v, %v; want the link", k, le, err)
+		}
+	}
+}
+
+func TestFileStoreKeepsServingBrokenFile(t *testing.T) {
+	ctx := context.Background()
+	s, path := newTestFileStore(t, "links.json")
+	if _, err := s.Put(ctx, "foo", &pb.Link{Uri: "http://example.com/foo"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	const broken = `{"links": {"foo": `
+	if err := os.WriteFile(path, []byte(broken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the broken file is noticed", func() bool { return s.Ping(ctx) != nil })
+	if le, err := s.Get(ctx, "foo"); err != nil || le.GetLink().GetUri() != "http://example.com/foo" {
+		t.Errorf("Get(foo) = %v, %v; want the link from before the edit", le, err)
+	}
+	if _, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com/bar"}); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Put over a broken file returned %v, want an error naming it", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != broken {
+		t.Errorf("Put replaced the broken file with %q; want it left to be fixed", data)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"links": {"foo": {"uri": "http://example.com/fixed"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the fixed file is loaded", func() bool { return s.Ping(ctx) == nil })
+	if le, err := s.Get(ctx, "foo"); err != nil || le.GetLink().GetUri() != "http://example.com/fixed" {
+		t.Errorf("Get(foo) = %v, %v; want the fixed link", le, err)
+	}
+}
+
+func TestFileStoreNormalizesHandEdits(t *testing.T) {
+	ctx := context.Background()
+	s, path := newTestFileStore(t, "links.json")
+	if err := os.WriteFile(path, []byte(`{"links": {"my-link": {"uri": "http://example.com/{0}"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the edit is loaded", func() bool {
+		le, _ := s.Get(ctx, "mylink")
+		return le != nil
+	})
+	if le, _ := s.Get(ctx, "mylink"); le.GetRequiredPaths() != 1 {
+		t.Errorf("Get(mylink) = %v, want the hand-added link with one required path", le)
+	}
+	if err := s.Ping(ctx); err != nil {
+		t.Errorf("Ping after a valid edit = %v", err)
+	}
+}
+
+func TestFileStoreRejectsInvalidHandEdits(t *testing.T) {
+	for name, edit := range map[string]string{
+		"reserved key": `{"links": {"healthz": {"uri": "http://example.com/"}}}`,
+		"no scheme":    `{"links": {"bar": {"uri": "example.com"}}}`,
+		"collision":    `{"links": {"my-link": {"uri": "http://a/"}, "mylink": {"uri": "http://b/"}}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			s, path := newTestFileStore(t, "links.json")
+			if _, err := s.Put(ctx, "foo", &pb.Link{Uri: "http://example.com/foo"}); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(edit), 0600); err != nil {
+				t.Fatal(err)
+			}
+			eventually(t, "the rejected edit is noticed", func() bool { return s.Ping(ctx) != nil })
+			got := map[string]*pb.LinkEntry{}
+			s.Visit(ctx, func(k string, le *pb.LinkEntry) { got[k] = le })
+			if len(got) != 1 || got["foo"] == nil {
+				t.Errorf("after a rejected edit the store holds %v, want the last good links", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package links
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"jdtw.dev/token"
+)
+
+// Health check paths. Both are served ahead of the redirect handler, so
+// links stored under these keys could never be reached; validateLink
+// rejects them.
+const (
+	healthzKey = "healthz"
+	readyzKey  = "readyz"
+)
+
+// readyTimeout bounds the readiness checks, so that a wedged database
+// fails the probe instead of hanging it.
+const readyTimeout = 2 * time.Second
+
+// Pinger is implemented by stores that can check that their backend is
+// reachable. Stores without a backend to lose, like MemStore, needn't
+// implement it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ping checks store if it implements Pinger, and reports success otherwise.
+func ping(ctx context.Context, store Store) error {
+	if p, ok := store.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+type healthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func writeHealth(w http.ResponseWriter, code int, hs *healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(hs)
+}
+
+// healthz reports that the process is up and serving HTTP. It checks
+// nothing else, so a platform restarting unhealthy machines won't restart
+// one just because its database is briefly unavailable.
+func (s *server) healthz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, &healthStatus{Status: "ok"})
+	}
+}
+
+// readyz reports whether the server can do useful work: the store must be
+// reachable and the keyset loaded and non-empty, or the API would reject
+// every request.
+func (s *server) readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		hs := &healthStatus{Status: "ok", Checks: map[string]string{}}
+		code := http.StatusOK
+		fail := func(check, msg string) {
+			hs.Status = "unavailable"
+			hs.Checks[check] = msg
+			code = http.StatusServiceUnavailable
+		}
+
+		hs.Checks["store"] = "ok"
+		if err := ping(ctx, s.store); err != nil {
+			logger(r.Context()).Warn("readiness check failed", "check", "store", "error", err)
+			fail("store", err.Error())
+		}
+		hs.Checks["keyset"] = "ok"
+		switch {
+		case s.ks == nil:
+			fail("keyset", "server missing keyset")
+		case emptyKeyset(s.ks):
+			fail("keyset", "keyset has no keys")
+		}
+		writeHealth(w, code, hs)
+	}
+}
+
+// emptyKeyset reports whether ks holds no keys, by comparing it with a new
+// keyset as String renders them.
+func emptyKeyset(ks *token.VerificationKeyset) bool {
+	return ks.String() == token.NewKeyset().String()
+}
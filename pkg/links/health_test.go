@@ -0,0 +1,102 @@
+package links
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+	"jdtw.dev/token"
+)
+
+func getHealth(t *testing.T, srv http.Handler, path string) (int, *healthStatus) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %s Content-Type = %q, want application/json", path, ct)
+	}
+	hs := new(healthStatus)
+	if err := json.NewDecoder(rr.Body).Decode(hs); err != nil {
+		t.Fatalf("GET %s returned undecodable body: %v", path, err)
+	}
+	return rr.Code, hs
+}
+
+func TestHealthz(t *testing.T) {
+	// Liveness doesn't depend on the keyset or the store.
+	srv := NewHandler(NewMemStore(), nil, 0)
+	if code, hs := getHealth(t, srv, "/healthz"); code != http.StatusOK || hs.Status != "ok" {
+		t.Errorf("GET /healthz = %d %+v, want 200 ok", code, hs)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	keyset, _ := tokentest.GenerateKey(t, "test")
+
+	t.Run("ready", func(t *testing.T) {
+		srv := NewHandler(newTestSQLiteStore(t), keyset, 0)
+		code, hs := getHealth(t, srv, "/readyz")
+		if code != http.StatusOK || hs.Status != "ok" {
+			t.Errorf("GET /readyz = %d %+v, want 200 ok", code, hs)
+		}
+		if hs.Checks["store"] != "ok" || hs.Checks["keyset"] != "ok" {
+			t.Errorf("GET /readyz checks = %v, want store and keyset ok", hs.Checks)
+		}
+	})
+
+	t.Run("store unreachable", func(t *testing.T) {
+		store := newTestSQLiteStore(t)
+		// Even behind the cache, readiness must reach the database.
+		srv := NewHandler(NewCachedStore(store, 10, 0), keyset, 0)
+		store.Close()
+		code, hs := getHealth(t, srv, "/readyz")
+		if code != http.StatusServiceUnavailable || hs.Checks["store"] == "ok" {
+			t.Errorf("GET /readyz = %d %+v, want 503 with a failed store check", code, hs)
+		}
+	})
+
+	t.Run("no keyset", func(t *testing.T) {
+		srv := NewHandler(NewMemStore(), nil, 0)
+		code, hs := getHealth(t, srv, "/readyz")
+		if code != http.StatusServiceUnavailable || hs.Checks["keyset"] == "ok" {
+			t.Errorf("GET /readyz = %d %+v, want 503 with a failed keyset check", code, hs)
+		}
+	})
+
+	t.Run("empty keyset", func(t *testing.T) {
+		srv := NewHandler(NewMemStore(), token.NewKeyset(), 0)
+		code, hs := getHealth(t, srv, "/readyz")
+		if code != http.StatusServiceUnavailable || hs.Checks["keyset"] == "ok" {
+			t.Errorf("GET /readyz = %d %+v, want 503 with a failed keyset check", code, hs)
+		}
+	})
+}
+
+func TestHealthChecksAreNotLogged(t *testing.T) {
+	logs := captureLogs(t)
+	srv := NewHandler(NewMemStore(), nil, 0)
+	getHealth(t, srv, "/healthz")
+	getHealth(t, srv, "/readyz")
+	if lines := logs(); findLog(lines, "request") != nil {
+		t.Errorf("health checks wrote access logs: %v", lines)
+	}
+}
+
+func TestPutRejectsHealthKeys(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+	for _, key := range []string{"healthz", "ready-z"} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("PUT", "/api/links/"+key, marshalLink(t, "http://example.com"))
+		signRequest(t, priv, req)
+		srv.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("PUT /api/links/%s returned %d, want %d", key, rr.Code, http.StatusBadRequest)
+		}
+	}
+	if sc := postLinks(t, srv, priv, map[string]string{"healthz": "https://example.com"}).StatusCode; sc != http.StatusBadRequest {
+		t.Errorf("POST with a healthz link returned %d, want %d", sc, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,62 @@
+package links
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var requestLogCtxKey = &contextKey{"RequestLog"}
+
+// requestLog collects attributes that handlers further down the chain learn
+// about a request, such as the authenticated subject, so that the access log
+// line written after the response can include them. It is shared by pointer
+// through the request context because handlers only get to derive new
+// contexts, not modify the one the logging middleware holds.
+type requestLog struct {
+	subject string
+}
+
+// logger returns the default logger annotated with the request's ID and,
+// once authenticated, its subject.
+func logger(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	if rid := middleware.GetReqID(ctx); rid != "" {
+		l = l.With("request_id", rid)
+	}
+	if sub := subject(ctx); sub != "" {
+		l = l.With("subject", sub)
+	}
+	return l
+}
+
+// logRequests writes one structured access log line per request, replacing
+// middleware.Logger's text format.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rl := &requestLog{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), requestLogCtxKey, rl)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"latency", time.Since(start),
+			"remote_addr", r.RemoteAddr,
+		}
+		if rl.subject != "" {
+			attrs = append(attrs, "subject", rl.subject)
+		}
+		logger(r.Context()).Info("request", attrs...)
+	})
+}
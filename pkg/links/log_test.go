@@ -0,0 +1,102 @@
+package links
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+)
+
+// captureLogs points the default logger at a JSON buffer for the duration of
+// the test and returns a function that decodes every line logged so far.
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+	return func() []map[string]any {
+		var lines []map[string]any
+		dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+		for dec.More() {
+			var line map[string]any
+			if err := dec.Decode(&line); err != nil {
+				t.Fatalf("decoding log line failed: %v", err)
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+}
+
+func findLog(lines []map[string]any, msg string) map[string]any {
+	for _, l := range lines {
+		if l["msg"] == msg {
+			return l
+		}
+	}
+	return nil
+}
+
+func TestLogsCarryRequestAttributes(t *testing.T) {
+	logs := captureLogs(t)
+	keyset, priv := tokentest.GenerateKey(t, "alice")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	req := httptest.NewRequest("PUT", "/api/links/foo", marshalLink(t, "https://example.com"))
+	signRequest(t, priv, req)
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+
+	lines := logs()
+	added := findLog(lines, "link added")
+	if added == nil {
+		t.Fatalf("no \"link added\" log line in %v", lines)
+	}
+	for k, want := range map[string]any{"subject": "alice", "key": "foo", "target": "https://example.com"} {
+		if added[k] != want {
+			t.Errorf("link added: %s = %v, want %v", k, added[k], want)
+		}
+	}
+	if added["request_id"] == nil {
+		t.Error("link added: missing request_id")
+	}
+
+	access := findLog(lines, "request")
+	if access == nil {
+		t.Fatalf("no access log line in %v", lines)
+	}
+	if access["subject"] != "alice" || access["status"] != float64(201) || access["latency"] == nil {
+		t.Errorf("access log = %v, want subject alice, status 201 and a latency", access)
+	}
+
+	redirect := findLog(lines, "redirecting")
+	if redirect == nil || redirect["key"] != "foo" || redirect["target"] != "https://example.com" {
+		t.Errorf("redirect log = %v, want key foo and target https://example.com", redirect)
+	}
+}
+
+func TestAuthFailureLogsStructuredFields(t *testing.T) {
+	logs := captureLogs(t)
+	keyset, _ := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	req := httptest.NewRequest("GET", "/api/links", nil)
+	req.Header.Set("Cookie", "secret=hunter2")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := findLog(logs(), "request unauthorized")
+	if line == nil {
+		t.Fatal("no \"request unauthorized\" log line")
+	}
+	if line["reason"] != "missing_token" || line["path"] != "/api/links" || line["method"] != "GET" {
+		t.Errorf("unauthorized log = %v, want reason, method and path", line)
+	}
+	// The old request dump copied every header into the logs.
+	if data, _ := json.Marshal(line); bytes.Contains(data, []byte("hunter2")) {
+		t.Errorf("unauthorized log = %s, leaked a request header", data)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"sync"
 
+	"google.golang.org/protobuf/proto"
 	pb "jdtw.dev/links/proto/links"
 )
 
-// MemStore is an in-memory store of links.
+// MemStore is an in-memory store of links. Like the database-backed
+// stores, it fails without doing anything if the context is already done.
 type MemStore struct {
 	entries map[string]*pb.LinkEntry
 	sync.RWMutex
@@ -20,12 +22,18 @@ func NewMemStore() *MemStore {
 }
 
 func (s *MemStore) Get(ctx context.Context, k string) (*pb.LinkEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.RLock()
 	defer s.RUnlock()
 	return s.entries[k], nil
 }
 
 func (s *MemStore) Put(ctx context.Context, k string, l *pb.Link) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	le := &pb.LinkEntry{
 		Link:          l,
 		RequiredPaths: requiredPaths(l),
@@ -37,14 +45,116 @@ func (s *MemStore) Put(ctx context.Context, k string, l *pb.Link) (bool, error)
 	return !present, nil
 }
 
-func (s *MemStore) Delete(ctx context.Context, k string) error {
+func (s *MemStore) PutAll(ctx context.Context, links map[string]*pb.Link) (int, int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	entries := newEntries(links)
+	s.Lock()
+	defer s.Unlock()
+	created, updated := s.putAll(entries)
+	return created, updated, nil
+}
+
+func (s *MemStore) ReplaceAll(ctx context.Context, links map[string]*pb.Link) (int, int, int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, 0, err
+	}
+	entries := newEntries(links)
 	s.Lock()
 	defer s.Unlock()
+	deleted := 0
+	for k := range s.entries {
+		if _, keep := entries[k]; !keep {
+			delete(s.entries, k)
+			deleted++
+		}
+	}
+	created, updated := s.putAll(entries)
+	return created, updated, deleted, nil
+}
+
+func newEntries(links map[string]*pb.Link) map[string]*pb.LinkEntry {
+	entries := make(map[string]*pb.LinkEntry, len(links))
+	for k, l := range links {
+		entries[k] = &pb.LinkEntry{
+			Link:          l,
+			RequiredPaths: requiredPaths(l),
+		}
+	}
+	return entries
+}
+
+// putAll stores entries, reporting how many were created and how many
+// updated. The caller must hold the write lock.
+func (s *MemStore) putAll(entries map[string]*pb.LinkEntry) (created, updated int) {
+	for k, le := range entries {
+		if _, present := s.entries[k]; present {
+			updated++
+		} else {
+			created++
+		}
+		s.entries[k] = le
+	}
+	return created, updated
+}
+
+func (s *MemStore) CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	cur, present := s.entries[k]
+	if present != (old != nil) || (present && !proto.Equal(cur.Link, old)) {
+		return false, nil
+	}
+	if new == nil {
+		delete(s.entries, k)
+	} else {
+		s.entries[k] = &pb.LinkEntry{
+			Link:          new,
+			RequiredPaths: requiredPaths(new),
+		}
+	}
+	return true, nil
+}
+
+func (s *MemStore) Delete(ctx context.Context, k string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	_, present := s.entries[k]
 	delete(s.entries, k)
+	return present, nil
+}
+
+func (s *MemStore) Rename(ctx context.Context, from, to string, overwrite, alias bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	le, present := s.entries[from]
+	if !present {
+		return ErrNotFound
+	}
+	if _, taken := s.entries[to]; taken && !overwrite {
+		return ErrExists
+	}
+	s.entries[to] = le
+	if !alias {
+		delete(s.entries, from)
+	}
 	return nil
 }
 
 func (s *MemStore) Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.RLock()
 	defer s.RUnlock()
 	for k, v := range s.entries {
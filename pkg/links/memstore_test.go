@@ -22,8 +22,57 @@ func TestGetPutDelete(t *testing.T) {
 	if got, _ := s.Get(ctx, "foo"); got.Link.Uri != "baz" {
 		t.Fatalf(`Get("foo") = %v; want "baz"`, got)
 	}
-	s.Delete(ctx, "foo")
+	if deleted, _ := s.Delete(ctx, "foo"); !deleted {
+		t.Fatalf(`Delete("foo") = false; want true`)
+	}
 	if got, _ := s.Get(ctx, "foo"); got != nil {
 		t.Fatalf(`Get("foo") = %q; want ""`, got)
 	}
+	if deleted, _ := s.Delete(ctx, "foo"); deleted {
+		t.Fatalf(`Delete("foo") again = true; want false`)
+	}
+}
+
+func TestPutAll(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStore()
+	s.Put(ctx, "existing", &pb.Link{Uri: "http://old"})
+
+	created, updated, err := s.PutAll(ctx, map[string]*pb.Link{
+		"existing": {Uri: "http://new"},
+		"fresh":    {Uri: "http://example.com/{0}"},
+	})
+	if err != nil {
+		t.Fatalf("PutAll failed: %v", err)
+	}
+	if created != 1 || updated != 1 {
+		t.Errorf("PutAll = %d created, %d updated; want 1, 1", created, updated)
+	}
+	if got, _ := s.Get(ctx, "existing"); got.Link.Uri != "http://new" {
+		t.Errorf(`Get("existing") = %v; want "http://new"`, got)
+	}
+	if got, _ := s.Get(ctx, "fresh"); got.RequiredPaths != 1 {
+		t.Errorf(`Get("fresh").RequiredPaths = %d; want 1`, got.RequiredPaths)
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemStore()
+	s.Put(ctx, "stale", &pb.Link{Uri: "http://stale"})
+	s.Put(ctx, "kept", &pb.Link{Uri: "http://old"})
+
+	created, updated, deleted, err := s.ReplaceAll(ctx, map[string]*pb.Link{
+		"kept":  {Uri: "http://new"},
+		"fresh": {Uri: "http://fresh"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	if created != 1 || updated != 1 || deleted != 1 {
+		t.Errorf("ReplaceAll = %d created, %d updated, %d deleted; want 1, 1, 1", created, updated, deleted)
+	}
+	if got, _ := s.Get(ctx, "stale"); got != nil {
+		t.Errorf(`Get("stale") = %v; want nil`, got)
+	}
 }
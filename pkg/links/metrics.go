@@ -0,0 +1,128 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"jdtw.dev/links/pkg/metrics"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// registry holds every metric the server reports. It is process-wide, like
+// the default Prometheus registry, so that any number of handlers report
+// into a single /metrics page.
+var registry = metrics.NewRegistry()
+
+var (
+	redirects = registry.NewCounter("links_redirects_total",
+		"Redirect requests by outcome (found, not_found, bad_params, qr, error).", "outcome")
+	apiRequests = registry.NewCounter("links_api_requests_total",
+		"API requests by method, route and status code.", "method", "route", "code")
+	authFailures = registry.NewCounter("links_auth_failures_total",
+		"Rejected API requests by reason.", "reason")
+	storeLatency = registry.NewHistogram("links_store_duration_seconds",
+		"Latency of Store calls by method.", metrics.DefBuckets, "method")
+	linkCount = registry.NewGauge("links_links",
+		"Number of stored links, as of the last scrape.")
+	cacheHits = registry.NewCounter("links_cache_hits_total",
+		"Lookups answered by the link cache, including cached misses.")
+	cacheMisses = registry.NewCounter("links_cache_misses_total",
+		"Lookups the link cache passed on to the store.")
+)
+
+// MetricsHandler serves the server's metrics in the Prometheus text
+// exposition format. The link count is read from store on every scrape.
+func MetricsHandler(store Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 0
+		if err := store.Visit(r.Context(), func(string, *pb.LinkEntry) { n++ }); err == nil {
+			linkCount.Set(float64(n))
+		}
+		registry.ServeHTTP(w, r)
+	})
+}
+
+// countAPIRequests records the status code of every API request, labeled by
+// the matched route pattern rather than the raw path so that link keys don't
+// explode the number of series.
+func countAPIRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		apiRequests.Inc(r.Method, route, strconv.Itoa(status))
+	})
+}
+
+// instrumentedStore records the latency of every call to the wrapped Store.
+type instrumentedStore struct {
+	store Store
+}
+
+var (
+	_ Store  = instrumentedStore{}
+	_ Pinger = instrumentedStore{}
+)
+
+// NewInstrumentedStore wraps store, recording the latency of every call to
+// it in links_store_duration_seconds. Put it beneath a CachedStore, so that
+// the latencies are those of the backend rather than of cache hits.
+func NewInstrumentedStore(store Store) Store {
+	return instrumentedStore{store}
+}
+
+func observe(method string, start time.Time) {
+	storeLatency.Observe(time.Since(start).Seconds(), method)
+}
+
+func (s instrumentedStore) Get(ctx context.Context, k string) (*pb.LinkEntry, error) {
+	defer observe("Get", time.Now())
+	return s.store.Get(ctx, k)
+}
+
+func (s instrumentedStore) Put(ctx context.Context, k string, l *pb.Link) (bool, error) {
+	defer observe("Put", time.Now())
+	return s.store.Put(ctx, k, l)
+}
+
+func (s instrumentedStore) PutAll(ctx context.Context, links map[string]*pb.Link) (int, int, error) {
+	defer observe("PutAll", time.Now())
+	return s.store.PutAll(ctx, links)
+}
+
+func (s instrumentedStore) ReplaceAll(ctx context.Context, links map[string]*pb.Link) (int, int, int, error) {
+	defer observe("ReplaceAll", time.Now())
+	return s.store.ReplaceAll(ctx, links)
+}
+
+func (s instrumentedStore) CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error) {
+	defer observe("CompareAndSwap", time.Now())
+	return s.store.CompareAndSwap(ctx, k, old, new)
+}
+
+func (s instrumentedStore) Delete(ctx context.Context, k string) (bool, error) {
+	defer observe("Delete", time.Now())
+	return s.store.Delete(ctx, k)
+}
+
+func (s instrumentedStore) Rename(ctx context.Context, from, to string, overwrite, alias bool) error {
+	defer observe("Rename", time.Now())
+	return s.store.Rename(ctx, from, to, overwrite, alias)
+}
+
+func (s instrumentedStore) Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error {
+	defer observe("Visit", time.Now())
+	return s.store.Visit(ctx, visit)
+}
+
+func (s instrumentedStore) Ping(ctx context.Context) error {
+	return ping(ctx, s.store)
+}
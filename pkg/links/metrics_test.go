@@ -0,0 +1,85 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// The registry is process-wide, so these tests check how much each counter
+// moved rather than its absolute value.
+
+func TestRedirectMetrics(t *testing.T) {
+	store := NewMemStore()
+	store.Put(context.Background(), "foo", &pb.Link{Uri: "https://example.com"})
+	store.Put(context.Background(), "params", &pb.Link{Uri: "https://example.com/{0}"})
+	srv := NewHandler(store, nil, 0)
+
+	tests := []struct {
+		get     string
+		outcome string
+	}{
+		{"/foo", "found"},
+		{"/missing", "not_found"},
+		{"/params", "bad_params"},
+		{"/qr/foo", "qr"},
+	}
+	for _, tc := range tests {
+		before := redirects.Value(tc.outcome)
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.get, nil))
+		if got := redirects.Value(tc.outcome) - before; got != 1 {
+			t.Errorf("GET %s moved links_redirects_total{outcome=%q} by %v, want 1", tc.get, tc.outcome, got)
+		}
+	}
+}
+
+func TestAPIMetrics(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewInstrumentedStore(NewMemStore()), keyset, 0)
+
+	before := apiRequests.Value("PUT", "/api/links/{link}", "201")
+	putsBefore := storeLatency.Count("Put")
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/api/links/foo", marshalLink(t, "https://example.com"))
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	if got := apiRequests.Value("PUT", "/api/links/{link}", "201") - before; got != 1 {
+		t.Errorf("PUT moved links_api_requests_total by %v, want 1", got)
+	}
+	if got := storeLatency.Count("Put") - putsBefore; got != 1 {
+		t.Errorf("PUT recorded %d Store.Put latencies, want 1", got)
+	}
+
+	missing := authFailures.Value("missing_token")
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/links", nil))
+	if got := authFailures.Value("missing_token") - missing; got != 1 {
+		t.Errorf("unsigned GET moved links_auth_failures_total{reason=missing_token} by %v, want 1", got)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	store := NewMemStore()
+	store.Put(context.Background(), "foo", &pb.Link{Uri: "https://example.com"})
+	store.Put(context.Background(), "bar", &pb.Link{Uri: "https://example.com"})
+
+	rr := httptest.NewRecorder()
+	MetricsHandler(store).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /metrics returned %d, want 200", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"links_links 2\n",
+		"# TYPE links_redirects_total counter\n",
+		"# TYPE links_store_duration_seconds histogram\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /metrics is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var basePathCtxKey = &contextKey{"BasePath"}
+
+// mountPoint lets the handler be served under a path prefix, e.g. /go/foo
+// for the link foo. http.StripPrefix already leaves r.URL.Path relative to
+// the prefix, but a chi router mounting the handler passes the full path
+// along and keeps the relative one in its routing context; mountPoint
+// rewrites the latter case to look like the former, so that everything
+// past it, including the Connect handler, sees paths relative to the
+// mount point. Either way, the path the client asked for is still in
+// r.RequestURI, and the prefix is whatever that adds to the relative path.
+func mountPoint(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, rawPath := r.URL.Path, r.URL.RawPath
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+			// chi routes on the escaped path when there is one.
+			path, rawPath = rctx.RoutePath, ""
+			if r.URL.RawPath != "" {
+				p, err := url.PathUnescape(rctx.RoutePath)
+				if err != nil {
+					badRequest(w, r, "invalid path: %v", err)
+					return
+				}
+				path, rawPath = p, rctx.RoutePath
+			}
+		}
+		if path == "" {
+			path = "/"
+		}
+
+		full := r.URL.Path
+		if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+			full = u.Path
+		}
+		base, ok := strings.CutSuffix(full, path)
+		if !ok && path == "/" {
+			// The prefix itself, without a trailing slash.
+			base, ok = full, true
+		}
+		if !ok {
+			base = ""
+		}
+		base = strings.TrimSuffix(base, "/")
+
+		if base == "" && path == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), basePathCtxKey, base))
+		u := *r.URL
+		u.Path, u.RawPath = path, rawPath
+		r.URL = &u
+		next.ServeHTTP(w, r)
+	})
+}
+
+// basePath returns the path prefix the handler is mounted under, without a
+// trailing slash; it's empty at the root.
+func basePath(ctx context.Context) string {
+	base, _ := ctx.Value(basePathCtxKey).(string)
+	return base
+}
+
+// linkPath returns the path of the link key on this server, for Location
+// headers.
+func linkPath(ctx context.Context, key string) string {
+	return basePath(ctx) + "/" + key
+}
+
+// requestedURL returns a copy of r whose URL is the one the client asked
+// for, before any prefix was stripped. Tokens are signed over that URL.
+func requestedURL(r *http.Request) *http.Request {
+	if r.RequestURI == "" {
+		return r
+	}
+	u, err := url.ParseRequestURI(r.RequestURI)
+	if err != nil || u.String() == r.URL.String() {
+		return r
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = u
+	return r2
+}
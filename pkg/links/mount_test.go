@@ -0,0 +1,133 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/go-chi/chi/v5"
+	"jdtw.dev/links/pkg/client"
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+func TestMountedUnderPrefix(t *testing.T) {
+	mounts := map[string]func(http.Handler) http.Handler{
+		"StripPrefix": func(h http.Handler) http.Handler {
+			mux := http.NewServeMux()
+			mux.Handle("/go/", http.StripPrefix("/go", h))
+			return mux
+		},
+		"chi Mount": func(h http.Handler) http.Handler {
+			r := chi.NewRouter()
+			r.Mount("/go", h)
+			return r
+		},
+	}
+	for name, mount := range mounts {
+		t.Run(name, func(t *testing.T) {
+			keyset, priv := tokentest.GenerateKey(t, "test")
+			store := NewMemStore()
+			s := httptest.NewServer(mount(New(store, WithKeyset(keyset))))
+			t.Cleanup(s.Close)
+			base := s.URL + "/go"
+			ctx := context.Background()
+
+			// The API, with tokens signed over the prefixed path.
+			c := client.New(base, priv)
+			if err := c.Put("foo", "http://example.com/{0}"); err != nil {
+				t.Fatalf("Put(foo) failed: %v", err)
+			}
+			if err := c.Put(Index, "http://example.com/index"); err != nil {
+				t.Fatalf("Put(%s) failed: %v", Index, err)
+			}
+			if uri, err := c.Get("foo"); err != nil || uri != "http://example.com/{0}" {
+				t.Errorf("Get(foo) = %q, %v; want the stored link", uri, err)
+			}
+			if _, err := c.Get("nope"); !errors.Is(err, client.ErrNotFound) {
+				t.Errorf("Get(nope) returned %v, want ErrNotFound", err)
+			}
+			rpc := client.NewServiceClient(base, priv)
+			if res, err := rpc.Get(ctx, connect.NewRequest(&pb.GetRequest{Key: "foo"})); err != nil || res.Msg.GetUri() != "http://example.com/{0}" {
+				t.Errorf("RPC Get(foo) = %v, %v; want the stored link", res, err)
+			}
+
+			// Links back to the server include the prefix.
+			req, err := http.NewRequest("POST", base+"/api/links/new", marshal(t, &pb.CreateLinkRequest{
+				Link: &pb.Link{Uri: "http://example.com/new"},
+				Key:  "new",
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := priv.AuthorizeRequest(req, time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if loc := res.Header.Get("Location"); res.StatusCode != http.StatusCreated || loc != "/go/new" {
+				t.Errorf("create returned %d with Location %q, want 201 with /go/new", res.StatusCode, loc)
+			}
+
+			noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+			for path, want := range map[string]string{
+				"/go/foo/bar": "http://example.com/bar",
+				"/go/":        "http://example.com/index",
+				"/go/new":     "http://example.com/new",
+			} {
+				res, err := noFollow.Get(s.URL + path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if loc := res.Header.Get("Location"); res.StatusCode != http.StatusFound || loc != want {
+					t.Errorf("GET %s returned %d to %q, want 302 to %q", path, res.StatusCode, loc, want)
+				}
+			}
+
+			res, err = noFollow.Get(s.URL + "/go/qr/foo/bar")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if ct := res.Header.Get("Content-Type"); res.StatusCode != http.StatusOK || ct != "image/png" {
+				t.Errorf("GET /go/qr/foo/bar returned %d with Content-Type %q, want a PNG", res.StatusCode, ct)
+			}
+
+			res, err = noFollow.Get(s.URL + "/go/" + healthzKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("GET /go/%s returned %d, want 200", healthzKey, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestTokenForUnprefixedPathRejected(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	srv := http.StripPrefix("/go", New(NewMemStore(), WithKeyset(keyset)))
+
+	// Signed for /api/links, but sent to /go/api/links: the token doesn't
+	// cover the path the client asked for.
+	req := httptest.NewRequest("GET", "/api/links", nil)
+	signRequest(t, priv, req)
+	req.URL.Path = "/go/api/links"
+	req.RequestURI = "/go/api/links"
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("GET /go/api/links with a token for /api/links returned %d, want 401", rr.Code)
+	}
+}
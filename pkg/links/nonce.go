@@ -0,0 +1,97 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"jdtw.dev/token/nonce"
+)
+
+const (
+	sqlNonceInsert = "insert into nonces (nonce, expires) values (?, ?) on conflict (nonce) do nothing"
+	sqlNoncePrune  = "delete from nonces where expires < ?"
+)
+
+// nonceCleanupInterval is how often SQLNonceVerifier deletes nonces that
+// can no longer be replayed, matching the in-memory verifier NewHandler
+// uses by default.
+const nonceCleanupInterval = time.Minute
+
+// errNonceReused is returned for a token whose nonce was seen before.
+var errNonceReused = errors.New("token nonce already used")
+
+// SQLNonceVerifier is a nonce.Verifier that records the nonces of the
+// tokens it has accepted in the store's database. Unlike the default
+// in-memory verifier, it remembers them across restarts, and shares them
+// with any other server process using the same database, so neither lets
+// a captured token be replayed.
+type SQLNonceVerifier struct {
+	db            *sql.DB
+	insert, prune string
+	// skew is how long after a token expires the server still accepts it,
+	// and so how long its nonce must be kept past its expiry.
+	skew time.Duration
+	now  func() time.Time
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+var _ nonce.Verifier = &SQLNonceVerifier{}
+
+// NonceVerifier returns a verifier that records nonces in the store's
+// database, creating its table if necessary. skew must be at least the skew
+// the server is configured to allow.
+func (s *sqlStore) NonceVerifier(ctx context.Context, skew time.Duration) (*SQLNonceVerifier, error) {
+	if _, err := s.db.ExecContext(ctx, s.d.nonceSchema); err != nil {
+		return nil, fmt.Errorf("creating nonce table failed: %w", err)
+	}
+	return &SQLNonceVerifier{
+		db:     s.db,
+		insert: s.d.bind(sqlNonceInsert),
+		prune:  s.d.bind(sqlNoncePrune),
+		skew:   skew,
+		now:    time.Now,
+	}, nil
+}
+
+// Verify records the nonce of a token that expires at expiry, failing if
+// it has been recorded before.
+func (v *SQLNonceVerifier) Verify(ctx context.Context, nonce []byte, expiry time.Time) error {
+	if err := v.pruneExpired(ctx); err != nil {
+		// The nonce can still be checked; the table is just bigger than it
+		// needs to be until the next try.
+		logger(ctx).Warn("pruning expired nonces failed", "error", err)
+	}
+	res, err := v.db.ExecContext(ctx, v.insert, nonce, expiry.Add(v.skew).UnixNano())
+	if err != nil {
+		return fmt.Errorf("recording nonce failed: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("recording nonce failed: %w", err)
+	}
+	if n == 0 {
+		return errNonceReused
+	}
+	return nil
+}
+
+// pruneExpired deletes the nonces of tokens too old to be accepted anyway,
+// at most once every nonceCleanupInterval.
+func (v *SQLNonceVerifier) pruneExpired(ctx context.Context) error {
+	now := v.now()
+	v.mu.Lock()
+	if now.Sub(v.lastPrune) < nonceCleanupInterval {
+		v.mu.Unlock()
+		return nil
+	}
+	v.lastPrune = now
+	v.mu.Unlock()
+	_, err := v.db.ExecContext(ctx, v.prune, now.UnixNano())
+	return err
+}
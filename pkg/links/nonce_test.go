@@ -0,0 +1,129 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"jdtw.dev/links/pkg/tokentest"
+)
+
+// A token accepted before a restart must still be rejected after it, which
+// the in-memory verifier can't promise.
+func TestSQLiteNonceVerifierRejectsReplayAcrossRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "links.db")
+	keyset, priv := tokentest.GenerateKey(t, "test")
+
+	start := func() (http.Handler, *SQLiteStore) {
+		t.Helper()
+		store, err := NewSQLiteStore(ctx, path)
+		if err != nil {
+			t.Fatalf("NewSQLiteStore failed: %v", err)
+		}
+		nv, err := store.NonceVerifier(ctx, 0)
+		if err != nil {
+			t.Fatalf("NonceVerifier failed: %v", err)
+		}
+		return NewHandler(store, keyset, 0, WithNonceVerifier(nv)), store
+	}
+
+	req := httptest.NewRequest("GET", "/api/links", nil)
+	signRequest(t, priv, req)
+	captured := req.Header.Get("Authorization")
+
+	srv, store := start()
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first request returned %d, want 200", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("replay before restart returned %d, want 401", rr.Code)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	srv, store = start()
+	t.Cleanup(func() { store.Close() })
+	replay := httptest.NewRequest("GET", "/api/links", nil)
+	replay.Header.Set("Authorization", captured)
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, replay)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("replay after restart returned %d, want 401", rr.Code)
+	}
+
+	// Fresh tokens are still fine.
+	fresh := httptest.NewRequest("GET", "/api/links", nil)
+	signRequest(t, priv, fresh)
+	rr = httptest.NewRecorder()
+	srv.ServeHTTP(rr, fresh)
+	if rr.Code != http.StatusOK {
+		t.Errorf("fresh request after restart returned %d, want 200", rr.Code)
+	}
+}
+
+func TestSQLiteNonceVerifierPrunesExpiredNonces(t *testing.T) {
+	ctx := context.Background()
+	store := newTestSQLiteStore(t)
+	const skew = time.Minute
+	nv, err := store.NonceVerifier(ctx, skew)
+	if err != nil {
+		t.Fatalf("NonceVerifier failed: %v", err)
+	}
+	now := time.Now()
+	nv.now = func() time.Time { return now }
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := store.db.QueryRowContext(ctx, "select count(*) from nonces").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	if err := nv.Verify(ctx, []byte("old"), now.Add(time.Second)); err != nil {
+		t.Fatalf("Verify(old) failed: %v", err)
+	}
+	if err := nv.Verify(ctx, []byte("old"), now.Add(time.Second)); !errors.Is(err, errNonceReused) {
+		t.Errorf("Verify(old) again returned %v, want %v", err, errNonceReused)
+	}
+
+	// Within the skew, the expired token could still be accepted, so its
+	// nonce must survive a prune.
+	now = now.Add(skew)
+	if err := nv.Verify(ctx, []byte("new"), now.Add(time.Second)); err != nil {
+		t.Fatalf("Verify(new) failed: %v", err)
+	}
+	if got := count(); got != 2 {
+		t.Errorf("%d nonces recorded within the skew, want 2", got)
+	}
+	if err := nv.Verify(ctx, []byte("old"), now.Add(time.Second)); !errors.Is(err, errNonceReused) {
+		t.Errorf("Verify(old) within the skew returned %v, want %v", err, errNonceReused)
+	}
+
+	now = now.Add(nonceCleanupInterval)
+	if err := nv.Verify(ctx, []byte("newer"), now.Add(time.Second)); err != nil {
+		t.Fatalf("Verify(newer) failed: %v", err)
+	}
+	// Only old is past its expiry plus the skew.
+	if got := count(); got != 2 {
+		t.Errorf("%d nonces recorded after old expired, want new and newer", got)
+	}
+	var n int
+	if err := store.db.QueryRowContext(ctx, "select count(*) from nonces where nonce = ?", []byte("old")).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Error("old's nonce survived a prune after it expired")
+	}
+}
@@ -0,0 +1,22 @@
+package links
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes the HTTP API for clients in other languages. It is
+// written by hand; TestOpenAPICoversRoutes fails if a route is added to
+// routes() without being documented here.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPI serves openAPISpec. It needs no token, so that client generators
+// can fetch it.
+func (s *server) openAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	}
+}
@@ -0,0 +1,125 @@
+package links
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"jdtw.dev/links/pkg/tokentest"
+	"jdtw.dev/links/proto/links/linksconnect"
+)
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	keyset, _ := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0).(*server)
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json isn't valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routed := make(map[string]bool)
+	if err := chi.Walk(srv.Mux, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		switch route {
+		case "/" + linksconnect.LinksServiceName + "/*":
+			// Described by links.proto.
+			return nil
+		case "/*":
+			// The redirect handler, which takes the key from the path.
+			route = "/{link}"
+		}
+		routed[method+" "+route] = true
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var undocumented, unrouted []string
+	for r := range routed {
+		if !documented[r] {
+			undocumented = append(undocumented, r)
+		}
+	}
+	for d := range documented {
+		if !routed[d] {
+			unrouted = append(unrouted, d)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unrouted)
+	for _, r := range undocumented {
+		t.Errorf("route %s is missing from openapi.json", r)
+	}
+	for _, d := range unrouted {
+		t.Errorf("openapi.json documents %s, which isn't routed", d)
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	var doc map[string]any
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json isn't valid JSON: %v", err)
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var node any = doc
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := node.(map[string]any)
+					node = m[part]
+				}
+				if node == nil {
+					t.Errorf("$ref %q doesn't resolve", ref)
+				}
+			}
+			for _, e := range v {
+				walk(e)
+			}
+		case []any:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestOpenAPIServedWithoutToken(t *testing.T) {
+	keyset, _ := tokentest.GenerateKey(t, "test")
+	srv := NewHandler(NewMemStore(), keyset, 0)
+
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json returned %d, want 200", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if !json.Valid(rr.Body.Bytes()) {
+		t.Error("response isn't valid JSON")
+	}
+}
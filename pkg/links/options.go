@@ -0,0 +1,73 @@
+package links
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"jdtw.dev/token"
+	"jdtw.dev/token/nonce"
+)
+
+// Option configures the handler returned by New.
+type Option func(*server)
+
+// WithKeyset sets the keys whose tokens the API accepts. Without one, every
+// API request is rejected.
+func WithKeyset(ks *token.VerificationKeyset) Option {
+	return func(s *server) {
+		s.ks = ks
+	}
+}
+
+// WithSkew sets how far the clocks of clients may be from the server's
+// when checking their tokens. The default is none.
+func WithSkew(skew time.Duration) Option {
+	return func(s *server) {
+		s.skew = skew
+	}
+}
+
+// WithNonceVerifier sets the verifier that rejects replayed tokens. The
+// default keeps seen nonces in memory, which a restart forgets and other
+// server processes don't share.
+func WithNonceVerifier(nv nonce.Verifier) Option {
+	return func(s *server) {
+		s.nv = nv
+	}
+}
+
+// WithClock sets the clock behind the times the server reports, such as in
+// whoami responses. The default is time.Now. Token expiry is checked by the
+// token library against the system clock regardless.
+func WithClock(now func() time.Time) Option {
+	return func(s *server) {
+		s.now = now
+	}
+}
+
+// WithReadOnly starts the server refusing writes, as it can also be made to
+// through PUT /api/readonly. Redirects and the rest of the API that only
+// reads go on working.
+func WithReadOnly(readOnly bool) Option {
+	return func(s *server) {
+		s.readOnly.SetReadOnly(readOnly)
+	}
+}
+
+// New returns a handler serving the links in store, as configured by opts.
+func New(store Store, opts ...Option) http.Handler {
+	readOnly := NewReadOnlyStore(store, false)
+	srv := &server{
+		store:    readOnly,
+		readOnly: readOnly,
+		nv:       nonce.NewMapVerifier(time.Minute),
+		now:      time.Now,
+		Mux:      chi.NewRouter(),
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	srv.routes()
+	return srv
+}
@@ -0,0 +1,48 @@
+package links
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	then := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := New(NewMemStore(),
+		WithKeyset(keyset),
+		WithSkew(time.Minute),
+		WithClock(func() time.Time { return then }))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/whoami", nil)
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET /api/whoami returned %d, want 200", rr.Code)
+	}
+	got := new(pb.WhoAmIResponse)
+	unmarshal(t, rr.Body, got)
+	if st := got.GetServerTime().AsTime(); !st.Equal(then) {
+		t.Errorf("server time = %v, want %v from WithClock", st, then)
+	}
+}
+
+// Without WithKeyset there is no key to trust, so the API must refuse
+// everything rather than let anything through.
+func TestNewWithoutKeysetFailsClosed(t *testing.T) {
+	_, priv := tokentest.GenerateKey(t, "test")
+	srv := New(NewMemStore())
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/links", nil)
+	signRequest(t, priv, req)
+	srv.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/links returned %d, want 401", rr.Code)
+	}
+}
@@ -0,0 +1,74 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// postgresDialect is the PostgreSQL flavor of the SQL store. Postgres runs
+// transactions concurrently, so they are made serializable, which gives
+// the same guarantees SQLite's single writer does; a transaction that
+// loses out to a concurrent one is aborted and tried again.
+var postgresDialect = &sqlDialect{
+	schema: `create table if not exists links (
+  path text primary key,
+  link text not null,
+  segments integer not null,
+  managed boolean not null default false
+)`,
+	columns:    "select column_name from information_schema.columns where table_schema = current_schema() and table_name = 'links'",
+	addManaged: "alter table links add column managed boolean not null default false",
+	nonceSchema: `create table if not exists nonces (
+  nonce bytea primary key,
+  expires bigint not null
+)`,
+	rebind:    numberedPlaceholders,
+	txOptions: &sql.TxOptions{Isolation: sql.LevelSerializable},
+	retryable: func(err error) bool {
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) {
+			return false
+		}
+		switch pqErr.Code.Name() {
+		case "serialization_failure", "deadlock_detected":
+			return true
+		}
+		return false
+	},
+}
+
+// PostgresStore is a Store backed by a PostgreSQL database. Unlike
+// SQLiteStore, it can be shared by any number of server processes, on any
+// number of machines.
+type PostgresStore struct {
+	*sqlStore
+}
+
+var _ Store = &PostgresStore{}
+
+// NewPostgresStore connects to the PostgreSQL database at url (a
+// postgres:// URL or a key=value connection string) and applies the
+// schema.
+func NewPostgresStore(ctx context.Context, url string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open failed: %w", err)
+	}
+	s, err := openSQLStore(ctx, db, postgresDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{s}, nil
+}
+
+// Close closes the database connections.
+func (s *PostgresStore) Close() error {
+	if s.sqlStore == nil {
+		return nil
+	}
+	return s.db.Close()
+}
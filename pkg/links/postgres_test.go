@@ -0,0 +1,101 @@
+package links
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+// testPostgresURL returns the database the PostgreSQL tests run against,
+// skipping the test if TEST_DATABASE_URL doesn't name one. Each test gets a
+// schema of its own, dropped when it finishes, so tests can't see each
+// other's links and nothing is left behind.
+func testPostgresURL(t *testing.T) string {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema := "links_test_" + strings.ToLower(rand.Text()[:12])
+	if _, err := db.ExecContext(ctx, "create schema "+schema); err != nil {
+		t.Fatalf("creating schema failed: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.ExecContext(ctx, "drop schema "+schema+" cascade"); err != nil {
+			t.Errorf("dropping schema %s failed: %v", schema, err)
+		}
+	})
+
+	// Both connection string forms can carry the search path.
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+func newTestPostgresStore(t *testing.T) *PostgresStore {
+	t.Helper()
+	s, err := NewPostgresStore(context.Background(), testPostgresURL(t))
+	if err != nil {
+		t.Fatalf("NewPostgresStore failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPostgresMigratesManagedColumn(t *testing.T) {
+	ctx := context.Background()
+	dsn := testPostgresURL(t)
+
+	// Create a table with the schema from before the managed column.
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	for _, stmt := range []string{
+		"create table links (path text primary key, link text not null, segments integer not null)",
+		"insert into links (path, link, segments) values ('foo', 'http://example.com', 0)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	s, err := NewPostgresStore(ctx, dsn)
+	if err != nil {
+		t.Fatalf("NewPostgresStore failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if le, err := s.Get(ctx, "foo"); err != nil || le.GetLink().GetUri() != "http://example.com" || le.GetLink().GetManaged() {
+		t.Errorf("Get(foo) after migration = %v, %v; want the old, unmanaged link", le, err)
+	}
+	if _, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com", Managed: true}); err != nil {
+		t.Fatalf("Put after migration failed: %v", err)
+	}
+}
+
+func TestNumberedPlaceholders(t *testing.T) {
+	if got, want := numberedPlaceholders(sqlPut), strings.NewReplacer("?, ?, ?, ?", "$1, $2, $3, $4").Replace(sqlPut); got != want {
+		t.Errorf("numberedPlaceholders(%q) = %q, want %q", sqlPut, got, want)
+	}
+	if got, want := numberedPlaceholders(sqlGet), "select link, segments, managed from links where path=$1"; got != want {
+		t.Errorf("numberedPlaceholders(%q) = %q, want %q", sqlGet, got, want)
+	}
+}
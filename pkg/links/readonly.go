@@ -0,0 +1,120 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	pb "jdtw.dev/links/proto/links"
+)
+
+// ReadOnlyStore is a Store that can be switched into refusing writes, to
+// freeze the links during a migration or an incident while they go on
+// being read. While it is read-only, every write fails with ErrReadOnly
+// without reaching the underlying store; reads always go through.
+type ReadOnlyStore struct {
+	store    Store
+	readOnly atomic.Bool
+}
+
+var (
+	_ Store  = &ReadOnlyStore{}
+	_ Pinger = &ReadOnlyStore{}
+)
+
+// NewReadOnlyStore wraps store, refusing writes to it if readOnly is set.
+func NewReadOnlyStore(store Store, readOnly bool) *ReadOnlyStore {
+	s := &ReadOnlyStore{store: store}
+	s.readOnly.Store(readOnly)
+	return s
+}
+
+// ReadOnly reports whether writes are being refused.
+func (s *ReadOnlyStore) ReadOnly() bool {
+	return s.readOnly.Load()
+}
+
+// SetReadOnly starts or stops refusing writes. A write already under way
+// when the store is made read-only may still complete.
+func (s *ReadOnlyStore) SetReadOnly(readOnly bool) {
+	s.readOnly.Store(readOnly)
+}
+
+func (s *ReadOnlyStore) Get(ctx context.Context, k string) (*pb.LinkEntry, error) {
+	return s.store.Get(ctx, k)
+}
+
+func (s *ReadOnlyStore) Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error {
+	return s.store.Visit(ctx, visit)
+}
+
+func (s *ReadOnlyStore) Put(ctx context.Context, k string, l *pb.Link) (bool, error) {
+	if s.ReadOnly() {
+		return false, ErrReadOnly
+	}
+	return s.store.Put(ctx, k, l)
+}
+
+func (s *ReadOnlyStore) PutAll(ctx context.Context, links map[string]*pb.Link) (int, int, error) {
+	if s.ReadOnly() {
+		return 0, 0, ErrReadOnly
+	}
+	return s.store.PutAll(ctx, links)
+}
+
+func (s *ReadOnlyStore) ReplaceAll(ctx context.Context, links map[string]*pb.Link) (int, int, int, error) {
+	if s.ReadOnly() {
+		return 0, 0, 0, ErrReadOnly
+	}
+	return s.store.ReplaceAll(ctx, links)
+}
+
+func (s *ReadOnlyStore) CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error) {
+	if s.ReadOnly() {
+		return false, ErrReadOnly
+	}
+	return s.store.CompareAndSwap(ctx, k, old, new)
+}
+
+func (s *ReadOnlyStore) Delete(ctx context.Context, k string) (bool, error) {
+	if s.ReadOnly() {
+		return false, ErrReadOnly
+	}
+	return s.store.Delete(ctx, k)
+}
+
+func (s *ReadOnlyStore) Rename(ctx context.Context, from, to string, overwrite, alias bool) error {
+	if s.ReadOnly() {
+		return ErrReadOnly
+	}
+	return s.store.Rename(ctx, from, to, overwrite, alias)
+}
+
+// Ping checks the underlying store. Being read-only doesn't make the store
+// any less ready to serve.
+func (s *ReadOnlyStore) Ping(ctx context.Context) error {
+	return ping(ctx, s.store)
+}
+
+// getReadOnly reports whether the server is refusing writes, so that a UI
+// can stop offering them.
+func (s *server) getReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeBody(w, r, http.StatusOK, &pb.ReadOnlyStatus{ReadOnly: s.readOnly.ReadOnly()})
+	}
+}
+
+// setReadOnly switches read-only mode on or off, as the ReadOnlyStatus in
+// the request body says, and responds with the new status. The switch only
+// applies to this server process, and lasts until it restarts.
+func (s *server) setReadOnly() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := new(pb.ReadOnlyStatus)
+		if !readBody(w, r, status) {
+			return
+		}
+		s.readOnly.SetReadOnly(status.GetReadOnly())
+		logger(r.Context()).Warn("read-only mode set", "read_only", status.GetReadOnly())
+		writeBody(w, r, http.StatusOK, status)
+	}
+}
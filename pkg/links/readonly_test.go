@@ -0,0 +1,160 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"jdtw.dev/links/pkg/client"
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+func TestReadOnlyStore(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemStore()
+	if _, err := mem.Put(ctx, "foo", &pb.Link{Uri: "http://example.com/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewReadOnlyStore(mem, true)
+
+	writes := map[string]func() error{
+		"Put": func() error {
+			_, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com/bar"})
+			return err
+		},
+		"PutAll": func() error {
+			_, _, err := s.PutAll(ctx, map[string]*pb.Link{"bar": {Uri: "http://example.com/bar"}})
+			return err
+		},
+		"ReplaceAll": func() error {
+			_, _, _, err := s.ReplaceAll(ctx, map[string]*pb.Link{"bar": {Uri: "http://example.com/bar"}})
+			return err
+		},
+		"CompareAndSwap": func() error {
+			_, err := s.CompareAndSwap(ctx, "bar", nil, &pb.Link{Uri: "http://example.com/bar"})
+			return err
+		},
+		"Delete": func() error {
+			_, err := s.Delete(ctx, "foo")
+			return err
+		},
+		"Rename": func() error { return s.Rename(ctx, "foo", "bar", false, false) },
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s while read-only returned %v, want %v", name, err, ErrReadOnly)
+		}
+	}
+	got := map[string]*pb.LinkEntry{}
+	if err := s.Visit(ctx, func(k string, le *pb.LinkEntry) { got[k] = le }); err != nil || len(got) != 1 || got["foo"] == nil {
+		t.Errorf("after refused writes the store holds %v, %v; want just foo", got, err)
+	}
+
+	s.SetReadOnly(false)
+	if s.ReadOnly() {
+		t.Error("ReadOnly() = true after SetReadOnly(false)")
+	}
+	if _, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com/bar"}); err != nil {
+		t.Errorf("Put after SetReadOnly(false) failed: %v", err)
+	}
+}
+
+func TestReadOnlyServer(t *testing.T) {
+	ctx := context.Background()
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	if _, err := store.Put(ctx, "foo", &pb.Link{Uri: "http://example.com/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := New(store, WithKeyset(keyset), WithReadOnly(true))
+
+	do := func(method, path string, body string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if body != "" {
+			req = httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		signRequest(t, priv, req)
+		rr := httptest.NewRecorder()
+		srv.ServeHTTP(rr, req)
+		return rr.Result()
+	}
+	readOnly := func() bool {
+		t.Helper()
+		resp := do("GET", "/api/readonly", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /api/readonly returned %d", resp.StatusCode)
+		}
+		status := new(pb.ReadOnlyStatus)
+		unmarshal(t, resp.Body, status)
+		return status.GetReadOnly()
+	}
+
+	if !readOnly() {
+		t.Error("server started WithReadOnly(true) reports it isn't read-only")
+	}
+	writes := []struct {
+		method, path, body string
+	}{
+		{"PUT", "/api/links/bar", `{"uri": "http://example.com/bar"}`},
+		{"POST", "/api/links", `{"links": {"bar": {"uri": "http://example.com/bar"}}}`},
+		{"POST", "/api/links/new", `{"link": {"uri": "http://example.com/bar"}}`},
+		{"DELETE", "/api/links/foo", ""},
+		{"POST", "/api/links/foo/rename?to=bar", ""},
+	}
+	for _, w := range writes {
+		resp := do(w.method, w.path, w.body)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s %s while read-only returned %d, want 503", w.method, w.path, resp.StatusCode)
+			continue
+		}
+		if e := decodeError(t, resp); e.Code != "read_only" || !strings.Contains(e.Message, "read-only") {
+			t.Errorf("%s %s while read-only returned %+v, want a read_only error", w.method, w.path, e)
+		}
+	}
+	if got := storedLinks(t, store); len(got) != 1 || got["foo"] != "http://example.com/foo" {
+		t.Errorf("after refused writes the store holds %v, want just foo", got)
+	}
+
+	// Reads, dry runs and redirects go on working.
+	if resp := do("GET", "/api/links/foo", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /api/links/foo while read-only returned %d, want 200", resp.StatusCode)
+	}
+	if resp := do("POST", "/api/links?dry_run=true", `{"links": {"bar": {"uri": "http://example.com/bar"}}}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("dry run while read-only returned %d, want 200", resp.StatusCode)
+	}
+	rr := httptest.NewRecorder()
+	srv.ServeHTTP(rr, httptest.NewRequest("GET", "/foo", nil))
+	if loc := rr.Result().Header.Get("Location"); loc != "http://example.com/foo" {
+		t.Errorf("redirect while read-only went to %q, want http://example.com/foo", loc)
+	}
+
+	resp := do("PUT", "/api/readonly", `{"readOnly": false}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /api/readonly returned %d", resp.StatusCode)
+	}
+	if readOnly() {
+		t.Error("server still read-only after PUT /api/readonly")
+	}
+	if resp := do("PUT", "/api/links/bar", `{"uri": "http://example.com/bar"}`); resp.StatusCode != http.StatusCreated {
+		t.Errorf("PUT after read-only mode was lifted returned %d, want 201", resp.StatusCode)
+	}
+}
+
+func TestReadOnlyRPC(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(New(NewMemStore(), WithKeyset(keyset), WithReadOnly(true)))
+	t.Cleanup(s.Close)
+	c := client.NewServiceClient(s.URL, priv)
+
+	_, err := c.Put(context.Background(), connect.NewRequest(&pb.PutRequest{Key: "foo", Link: &pb.Link{Uri: "http://example.com"}}))
+	if connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Errorf("Put while read-only returned %v, want Unavailable", err)
+	}
+}
@@ -1,13 +1,12 @@
 package links
 
 import (
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
 
-	"github.com/go-chi/chi/v5/middleware"
 	qrcode "github.com/skip2/go-qrcode"
+	"jdtw.dev/links/proto/links/linksconnect"
 )
 
 // Index is used for special handling for the root path; it is stored
@@ -19,6 +18,16 @@ const Index = ".index"
 // this key would never be reachable, so put() rejects it.
 const qrKey = "qr"
 
+// reservedKeys are the keys whose paths are served by something other than
+// redirect(), so that links stored under them would be unreachable.
+var reservedKeys = map[string]bool{
+	qrKey:      true,
+	healthzKey: true,
+	readyzKey:  true,
+	// LinksService is served under its name.
+	linksconnect.LinksServiceName: true,
+}
+
 // normalizeKey strips hyphens from a link key, so that e.g. "my-link" and
 // "mylink" are treated as the same link. Hyphens are purely a readability
 // aid when typing a URL.
@@ -28,8 +37,6 @@ func normalizeKey(k string) string {
 
 func (s *server) redirect() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		rid := middleware.GetReqID(r.Context())
-
 		// The first path segment is the key into our DB.
 		// The remaining path segments are paths to be appended
 		// or substituted in the redirect.
@@ -54,11 +61,13 @@ func (s *server) redirect() http.HandlerFunc {
 		key = normalizeKey(key)
 		le, err := s.store.Get(r.Context(), key)
 		if err != nil {
-			internalError(w, err, rid)
+			redirects.Inc("error")
+			internalError(w, r, err)
 			return
 		}
 		if le == nil {
-			http.NotFound(w, r)
+			redirects.Inc("not_found")
+			notFound(w, r, key)
 			return
 		}
 
@@ -67,13 +76,15 @@ func (s *server) redirect() http.HandlerFunc {
 		// we end up with "example.com/foo/bar/baz"
 		uri, paths, err := subst(le, paths)
 		if err != nil {
-			badRequest(w, "%s", err.Error())
+			redirects.Inc("bad_params")
+			badRequest(w, r, "%s", err.Error())
 			return
 		}
 
 		loc, err := url.Parse(uri)
 		if err != nil {
-			internalError(w, err, rid)
+			redirects.Inc("error")
+			internalError(w, r, err)
 			return
 		}
 		if len(paths) > 0 {
@@ -88,14 +99,17 @@ func (s *server) redirect() http.HandlerFunc {
 		if qr {
 			png, err := qrcode.Encode(loc.String(), qrcode.High, 256)
 			if err != nil {
-				internalError(w, err, rid)
+				redirects.Inc("error")
+				internalError(w, r, err)
 				return
 			}
+			redirects.Inc("qr")
 			w.Header().Set("Content-Type", "image/png")
 			w.Write(png)
 			return
 		}
-		log.Printf("[%s] redirecting %s to %s", rid, r.URL, loc)
+		redirects.Inc("found")
+		logger(r.Context()).Info("redirecting", "key", key, "target", loc.String())
 		http.Redirect(w, r, loc.String(), http.StatusFound)
 	}
 }
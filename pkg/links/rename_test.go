@@ -0,0 +1,55 @@
+package links
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+)
+
+func TestRenameAPI(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	srv := NewHandler(store, keyset, 0)
+	ctx := context.Background()
+	rename := func(path string) int {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, nil)
+		signRequest(t, priv, req)
+		srv.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	store.Put(ctx, "old", &pb.Link{Uri: "https://example.com/old"})
+	store.Put(ctx, "taken", &pb.Link{Uri: "https://example.com/taken"})
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/api/links/old/rename", http.StatusBadRequest},
+		{"/api/links/old/rename?to=o-l-d", http.StatusBadRequest},
+		{"/api/links/old/rename?to=qr", http.StatusBadRequest},
+		{"/api/links/old/rename?to=new&alias=sometimes", http.StatusBadRequest},
+		{"/api/links/missing/rename?to=new", http.StatusNotFound},
+		{"/api/links/old/rename?to=taken", http.StatusConflict},
+		{"/api/links/o-ld/rename?to=n-ew", http.StatusNoContent},
+		{"/api/links/new/rename?to=taken&overwrite=true&alias=true", http.StatusNoContent},
+	} {
+		if got := rename(tc.path); got != tc.want {
+			t.Errorf("POST %s returned %d, want %d", tc.path, got, tc.want)
+		}
+	}
+
+	want := map[string]string{
+		"new":   "https://example.com/old",
+		"taken": "https://example.com/old",
+	}
+	got := storedLinks(t, store)
+	if len(got) != len(want) || got["new"] != want["new"] || got["taken"] != want["taken"] {
+		t.Errorf("after renames the store holds %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+	pb "jdtw.dev/links/proto/links"
+	"jdtw.dev/links/proto/links/linksconnect"
+)
+
+// rpcServer implements LinksService on top of the same Store as the REST
+// API, enforcing the same rules. Requests are authenticated before they get
+// here, by the same middleware that guards /api.
+type rpcServer struct {
+	*server
+}
+
+var _ linksconnect.LinksServiceHandler = rpcServer{}
+
+// errInternal is all an RPC client is told about an internal error; the
+// details, which can describe the database, only go to the log.
+var errInternal = errors.New("internal error")
+
+// rpcInternal logs err and hides it behind an Internal error, the RPC
+// equivalent of internalError.
+func rpcInternal(ctx context.Context, err error) error {
+	logger(ctx).Error("internal error", "error", err)
+	return connect.NewError(connect.CodeInternal, errInternal)
+}
+
+// rpcStoreError is storeError for RPCs: a write refused because the server
+// is read-only fails with Unavailable.
+func rpcStoreError(ctx context.Context, err error) error {
+	if errors.Is(err, ErrReadOnly) {
+		return connect.NewError(connect.CodeUnavailable, err)
+	}
+	return rpcInternal(ctx, err)
+}
+
+func (s rpcServer) Get(ctx context.Context, req *connect.Request[pb.GetRequest]) (*connect.Response[pb.Link], error) {
+	k := normalizeKey(req.Msg.GetKey())
+	le, err := s.store.Get(ctx, k)
+	if err != nil {
+		return nil, rpcInternal(ctx, err)
+	}
+	if le == nil {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("link %q not found", k))
+	}
+	return connect.NewResponse(le.Link), nil
+}
+
+func (s rpcServer) Put(ctx context.Context, req *connect.Request[pb.PutRequest]) (*connect.Response[pb.PutResponse], error) {
+	k := normalizeKey(req.Msg.GetKey())
+	l := req.Msg.GetLink()
+	if err := validateLink(k, l); err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	created, err := s.store.Put(ctx, k, l)
+	if err != nil {
+		return nil, rpcStoreError(ctx, err)
+	}
+	log := logger(ctx).With("key", k, "target", l.GetUri())
+	if created {
+		log.Info("link added")
+	} else {
+		log.Info("link updated")
+	}
+	return connect.NewResponse(&pb.PutResponse{Created: created}), nil
+}
+
+func (s rpcServer) Delete(ctx context.Context, req *connect.Request[pb.DeleteRequest]) (*connect.Response[pb.DeleteResponse], error) {
+	k := normalizeKey(req.Msg.GetKey())
+	deleted, err := s.store.Delete(ctx, k)
+	if err != nil {
+		return nil, rpcStoreError(ctx, err)
+	}
+	if !deleted && !req.Msg.GetMissingOk() {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("link %q not found", k))
+	}
+	logger(ctx).Info("link deleted", "key", k)
+	return connect.NewResponse(&pb.DeleteResponse{}), nil
+}
+
+func (s rpcServer) List(ctx context.Context, req *connect.Request[pb.ListRequest]) (*connect.Response[pb.Links], error) {
+	lpb := &pb.Links{Links: make(map[string]*pb.Link)}
+	if err := s.store.Visit(ctx, func(k string, le *pb.LinkEntry) {
+		lpb.Links[k] = le.Link
+	}); err != nil {
+		return nil, rpcInternal(ctx, err)
+	}
+	return connect.NewResponse(lpb), nil
+}
+
+func (s rpcServer) BulkPut(ctx context.Context, req *connect.Request[pb.BulkPutRequest]) (*connect.Response[pb.BulkPutResponse], error) {
+	if len(req.Msg.GetLinks().GetLinks()) == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("no links in request"))
+	}
+	normalized, err := normalizeLinks(req.Msg.GetLinks().GetLinks())
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	replace := req.Msg.GetReplace()
+
+	if req.Msg.GetDryRun() {
+		diff, err := diffLinks(ctx, s.store, normalized, replace)
+		if err != nil {
+			return nil, rpcInternal(ctx, err)
+		}
+		return connect.NewResponse(&pb.BulkPutResponse{Diff: diff}), nil
+	}
+
+	var created, updated, deleted int
+	if replace {
+		created, updated, deleted, err = s.store.ReplaceAll(ctx, normalized)
+	} else {
+		created, updated, err = s.store.PutAll(ctx, normalized)
+	}
+	if err != nil {
+		return nil, rpcStoreError(ctx, err)
+	}
+	logger(ctx).Info("links imported", "replace", replace,
+		"count", len(normalized), "created", created, "updated", updated, "deleted", deleted)
+	return connect.NewResponse(&pb.BulkPutResponse{
+		Created: int32(created),
+		Updated: int32(updated),
+		Deleted: int32(deleted),
+	}), nil
+}
@@ -0,0 +1,111 @@
+package links
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"jdtw.dev/links/pkg/client"
+	"jdtw.dev/links/pkg/tokentest"
+	pb "jdtw.dev/links/proto/links"
+	"jdtw.dev/links/proto/links/linksconnect"
+)
+
+func newRPCTestServer(t *testing.T) (*MemStore, linksconnect.LinksServiceClient, string) {
+	t.Helper()
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	store := NewMemStore()
+	s := httptest.NewServer(NewHandler(store, keyset, 0))
+	t.Cleanup(s.Close)
+	return store, client.NewServiceClient(s.URL, priv), s.URL
+}
+
+func TestRPC(t *testing.T) {
+	store, c, _ := newRPCTestServer(t)
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, connect.NewRequest(&pb.GetRequest{Key: "foo"})); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Errorf("Get(foo) returned %v, want NotFound", err)
+	}
+
+	res, err := c.Put(ctx, connect.NewRequest(&pb.PutRequest{Key: "f-oo", Link: &pb.Link{Uri: "http://example.com/{0}"}}))
+	if err != nil {
+		t.Fatalf("Put(f-oo) failed: %v", err)
+	}
+	if !res.Msg.GetCreated() {
+		t.Errorf("Put(f-oo) = %v, want created", res.Msg)
+	}
+	if le, _ := store.Get(ctx, "foo"); le.GetRequiredPaths() != 1 {
+		t.Errorf("stored entry = %v, want it normalized under foo with one required path", le)
+	}
+	if _, err := c.Put(ctx, connect.NewRequest(&pb.PutRequest{Key: "qr", Link: &pb.Link{Uri: "http://example.com"}})); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("Put(qr) returned %v, want InvalidArgument", err)
+	}
+
+	got, err := c.Get(ctx, connect.NewRequest(&pb.GetRequest{Key: "foo"}))
+	if err != nil || got.Msg.GetUri() != "http://example.com/{0}" {
+		t.Errorf("Get(foo) = %v, %v; want the stored link", got, err)
+	}
+
+	bulk, err := c.BulkPut(ctx, connect.NewRequest(&pb.BulkPutRequest{
+		Links:   &pb.Links{Links: map[string]*pb.Link{"bar": {Uri: "http://example.com/bar"}}},
+		Replace: true,
+		DryRun:  true,
+	}))
+	if err != nil {
+		t.Fatalf("dry run BulkPut failed: %v", err)
+	}
+	if d := bulk.Msg.GetDiff(); len(d.GetAdded()) != 1 || len(d.GetDeleted()) != 1 {
+		t.Errorf("dry run BulkPut diff = %v, want bar added and foo deleted", d)
+	}
+	bulk, err = c.BulkPut(ctx, connect.NewRequest(&pb.BulkPutRequest{
+		Links: &pb.Links{Links: map[string]*pb.Link{"bar": {Uri: "http://example.com/bar"}}},
+	}))
+	if err != nil || bulk.Msg.GetCreated() != 1 || bulk.Msg.GetDiff() != nil {
+		t.Errorf("BulkPut = %v, %v; want one created", bulk, err)
+	}
+	if _, err := c.BulkPut(ctx, connect.NewRequest(&pb.BulkPutRequest{})); connect.CodeOf(err) != connect.CodeInvalidArgument {
+		t.Errorf("empty BulkPut returned %v, want InvalidArgument", err)
+	}
+
+	list, err := c.List(ctx, connect.NewRequest(&pb.ListRequest{}))
+	if err != nil || len(list.Msg.GetLinks()) != 2 {
+		t.Errorf("List = %v, %v; want foo and bar", list, err)
+	}
+
+	if _, err := c.Delete(ctx, connect.NewRequest(&pb.DeleteRequest{Key: "foo"})); err != nil {
+		t.Errorf("Delete(foo) failed: %v", err)
+	}
+	if _, err := c.Delete(ctx, connect.NewRequest(&pb.DeleteRequest{Key: "foo"})); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Errorf("second Delete(foo) returned %v, want NotFound", err)
+	}
+	if _, err := c.Delete(ctx, connect.NewRequest(&pb.DeleteRequest{Key: "foo", MissingOk: true})); err != nil {
+		t.Errorf("Delete(foo) with missing_ok failed: %v", err)
+	}
+}
+
+func TestRPCRequiresAuth(t *testing.T) {
+	_, _, url := newRPCTestServer(t)
+	c := client.NewServiceClient(url, nil)
+	_, err := c.List(context.Background(), connect.NewRequest(&pb.ListRequest{}))
+	if connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("unsigned List returned %v, want Unauthenticated", err)
+	}
+}
+
+func TestRPCHidesInternalErrors(t *testing.T) {
+	keyset, priv := tokentest.GenerateKey(t, "test")
+	s := httptest.NewServer(NewHandler(brokenDeleteStore{NewMemStore()}, keyset, 0))
+	t.Cleanup(s.Close)
+	c := client.NewServiceClient(s.URL, priv)
+
+	_, err := c.Delete(context.Background(), connect.NewRequest(&pb.DeleteRequest{Key: "foo"}))
+	if connect.CodeOf(err) != connect.CodeInternal {
+		t.Fatalf("Delete with a failing store returned %v, want Internal", err)
+	}
+	if strings.Contains(err.Error(), "disk on fire") {
+		t.Errorf("Delete with a failing store returned %v, which gives away the store's error", err)
+	}
+}
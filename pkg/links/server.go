@@ -1,52 +1,87 @@
 package links
 
 import (
-	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"jdtw.dev/links/proto/links/linksconnect"
 	"jdtw.dev/token"
 	"jdtw.dev/token/nonce"
 )
 
 type server struct {
 	store Store
-	ks    *token.VerificationKeyset
-	nv    nonce.Verifier
-	skew  time.Duration
+	// readOnly is store itself, kept as a ReadOnlyStore so that the API
+	// can switch writes off and on.
+	readOnly *ReadOnlyStore
+	ks       *token.VerificationKeyset
+	nv       nonce.Verifier
+	skew     time.Duration
+	now      func() time.Time
 	*chi.Mux
 }
 
 func (s *server) routes() {
 	s.Use(middleware.RequestID)
-	s.Use(middleware.Logger)
-	// REST API
-	s.Route("/api", func(r chi.Router) {
-		r.Use(s.authenticated())
-		// Get all links as a Links proto.
-		r.Get("/links", s.list())
-		// Bulk create or update from a Links proto.
-		r.Post("/links", s.bulkPut())
-		// Get a speficic link.
-		r.Get("/links/{link}", s.get())
-		// Create or update a link.
-		r.Put("/links/{link}", s.put())
-		// Remove a link.
-		r.Delete("/links/{link}", s.delete())
-	})
+	s.Use(mountPoint)
+
+	// Health checks are polled every few seconds, so they stay out of the
+	// access log.
+	s.Get("/"+healthzKey, s.healthz())
+	s.Get("/"+readyzKey, s.readyz())
+
+	s.Group(func(r chi.Router) {
+		r.Use(logRequests)
+		// REST API
+		r.Route("/api", func(r chi.Router) {
+			r.Use(countAPIRequests)
+			// The OpenAPI document describing everything in this function.
+			r.Get("/openapi.json", s.openAPI())
+			r.Group(func(r chi.Router) {
+				r.Use(s.authenticated())
+				r.Use(negotiateContent)
+				// Get all links as a Links proto.
+				r.Get("/links", s.list())
+				// Bulk create or update from a Links proto.
+				r.Post("/links", s.bulkPut())
+				// Create a link under a new key, generating one if asked.
+				r.Post("/links/new", s.create())
+				// Get a speficic link.
+				r.Get("/links/{link}", s.get())
+				// Create or update a link.
+				r.Put("/links/{link}", s.put())
+				// Remove a link.
+				r.Delete("/links/{link}", s.delete())
+				// Move a link to a new key.
+				r.Post("/links/{link}/rename", s.rename())
+				// Who the request authenticated as, for debugging keys and
+				// clocks.
+				r.Get("/whoami", s.whoami())
+				// Whether writes are refused, and switching that.
+				r.Get("/readonly", s.getReadOnly())
+				r.Put("/readonly", s.setReadOnly())
+			})
+		})
 
-	// Application
-	s.Get("/*", s.redirect())
+		// RPC API: LinksService, for callers that want typed stubs.
+		// It shares the REST API's authentication and metrics.
+		r.Group(func(r chi.Router) {
+			r.Use(countAPIRequests)
+			r.Use(s.authenticated())
+			r.Mount(linksconnect.NewLinksServiceHandler(rpcServer{s}))
+		})
+
+		// Application
+		r.Get("/*", s.redirect())
+	})
 }
 
-// NewHandler sets up routes based on the given key value store.
-func NewHandler(store Store, ks *token.VerificationKeyset, skew time.Duration) http.Handler {
-	srv := &server{store, ks, nonce.NewMapVerifier(time.Minute), skew, chi.NewRouter()}
-	srv.routes()
-	return srv
+// NewHandler sets up routes based on the given key value store. It is
+// New with the keyset and skew as options, kept for existing callers.
+func NewHandler(store Store, ks *token.VerificationKeyset, skew time.Duration, opts ...Option) http.Handler {
+	return New(store, append([]Option{WithKeyset(ks), WithSkew(skew)}, opts...)...)
 }
 
 type contextKey struct {
@@ -56,12 +91,3 @@ type contextKey struct {
 func (k *contextKey) String() string {
 	return "jdtw.dev/links " + k.name
 }
-
-func internalError(w http.ResponseWriter, err error, rid string) {
-	log.Printf("[%s] internal error: %v", rid, err)
-	http.Error(w, err.Error(), http.StatusInternalServerError)
-}
-
-func badRequest(w http.ResponseWriter, format string, a ...interface{}) {
-	http.Error(w, fmt.Sprintf(format, a...), http.StatusBadRequest)
-}
@@ -2,6 +2,8 @@ package links
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"path/filepath"
 	"testing"
 
@@ -20,164 +22,118 @@ func newTestSQLiteStore(t *testing.T) *SQLiteStore {
 	return s
 }
 
-func TestSQLitePutReportsCreatedVsUpdated(t *testing.T) {
-	s := newTestSQLiteStore(t)
+// The database file must survive being closed and reopened -- this is the
+// whole point of putting it on a volume.
+func TestSQLitePersistsAcrossReopen(t *testing.T) {
 	ctx := context.Background()
-	const key = "createdvsupdated"
+	path := filepath.Join(t.TempDir(), "links.db")
 
-	created, err := s.Put(ctx, key, &pb.Link{Uri: "http://example.com/first"})
+	first, err := NewSQLiteStore(ctx, path)
 	if err != nil {
-		t.Fatalf("Put (insert) failed: %v", err)
-	}
-	if !created {
-		t.Errorf("Put (insert) reported created=false, want true")
+		t.Fatalf("NewSQLiteStore failed: %v", err)
 	}
-
-	created, err = s.Put(ctx, key, &pb.Link{Uri: "http://example.com/second"})
-	if err != nil {
-		t.Fatalf("Put (update) failed: %v", err)
+	if _, err := first.Put(ctx, "persisted", &pb.Link{Uri: "http://example.com/persisted"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
 	}
-	if created {
-		t.Errorf("Put (update) reported created=true, want false")
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
 	}
 
-	le, err := s.Get(ctx, key)
+	second, err := NewSQLiteStore(ctx, path)
 	if err != nil {
-		t.Fatalf("Get failed: %v", err)
-	}
-	if got, want := le.Link.GetUri(), "http://example.com/second"; got != want {
-		t.Errorf("Get(%s) URI = %q, want %q", key, got, want)
+		t.Fatalf("reopening store failed: %v", err)
 	}
-}
-
-func TestSQLiteGetMissingKeyReturnsNil(t *testing.T) {
-	s := newTestSQLiteStore(t)
+	t.Cleanup(func() { second.Close() })
 
-	le, err := s.Get(context.Background(), "doesnotexist")
+	le, err := second.Get(ctx, "persisted")
 	if err != nil {
-		t.Fatalf("Get failed: %v", err)
+		t.Fatalf("Get after reopen failed: %v", err)
 	}
-	if le != nil {
-		t.Errorf("Get(missing) = %v, want nil", le)
+	if le == nil {
+		t.Fatal("Get after reopen = nil, want the entry written before close")
+	}
+	if got, want := le.Link.GetUri(), "http://example.com/persisted"; got != want {
+		t.Errorf("URI after reopen = %q, want %q", got, want)
 	}
 }
 
-func TestSQLiteDelete(t *testing.T) {
+// A write that fails part way through PutAll must roll back the whole batch.
+// A trigger makes the database itself reject one key, after others in the
+// same batch have already been written inside the transaction.
+func TestSQLitePutAllIsAtomic(t *testing.T) {
 	s := newTestSQLiteStore(t)
 	ctx := context.Background()
-	const key = "delete"
 
-	if _, err := s.Put(ctx, key, &pb.Link{Uri: "http://example.com"}); err != nil {
-		t.Fatalf("Put failed: %v", err)
-	}
-	if err := s.Delete(ctx, key); err != nil {
-		t.Fatalf("Delete failed: %v", err)
+	if _, err := s.db.ExecContext(ctx, `create trigger fail before insert on links
+		when new.path = 'boom' begin select raise(abort, 'injected fault'); end`); err != nil {
+		t.Fatalf("creating trigger failed: %v", err)
 	}
-	le, err := s.Get(ctx, key)
-	if err != nil {
-		t.Fatalf("Get after delete failed: %v", err)
+	links := map[string]*pb.Link{"boom": {Uri: "http://example.com/boom"}}
+	for i := 0; i < 10; i++ {
+		links[fmt.Sprint("ok", i)] = &pb.Link{Uri: "http://example.com/ok"}
 	}
-	if le != nil {
-		t.Errorf("Get after delete = %v, want nil", le)
+	if _, _, err := s.PutAll(ctx, links); err == nil {
+		t.Fatal("PutAll succeeded, want the injected fault")
 	}
-}
 
-// Deleting a key that was never present should be a no-op, matching the
-// in-memory store's behavior.
-func TestSQLiteDeleteMissingKeyIsNoOp(t *testing.T) {
-	s := newTestSQLiteStore(t)
-	if err := s.Delete(context.Background(), "neverexisted"); err != nil {
-		t.Errorf("Delete(missing) failed: %v", err)
-	}
-}
-
-func TestSQLiteVisit(t *testing.T) {
-	s := newTestSQLiteStore(t)
-	ctx := context.Background()
-
-	want := map[string]string{
-		"one":   "http://example.com/one",
-		"two":   "http://example.com/two",
-		"three": "http://example.com/three",
-	}
-	for k, uri := range want {
-		if _, err := s.Put(ctx, k, &pb.Link{Uri: uri}); err != nil {
-			t.Fatalf("Put(%s) failed: %v", k, err)
-		}
-	}
-
-	got := map[string]string{}
-	if err := s.Visit(ctx, func(k string, le *pb.LinkEntry) {
-		got[k] = le.Link.GetUri()
-	}); err != nil {
+	n := 0
+	if err := s.Visit(ctx, func(string, *pb.LinkEntry) { n++ }); err != nil {
 		t.Fatalf("Visit failed: %v", err)
 	}
-
-	if len(got) != len(want) {
-		t.Errorf("Visit saw %d entries, want %d", len(got), len(want))
-	}
-	for k, wantURI := range want {
-		if got[k] != wantURI {
-			t.Errorf("Visit(%s) URI = %q, want %q", k, got[k], wantURI)
-		}
+	if n != 0 {
+		t.Errorf("failed PutAll left %d links behind, want none", n)
 	}
 }
 
-// Put must persist the computed RequiredPaths so that {n} substitution keeps
-// working after a restart.
-func TestSQLitePutPersistsRequiredPaths(t *testing.T) {
+// Like PutAll, a failure part way through ReplaceAll must roll back the
+// deletions along with the writes.
+func TestSQLiteReplaceAllIsAtomic(t *testing.T) {
 	s := newTestSQLiteStore(t)
 	ctx := context.Background()
-	const key = "subst"
 
-	l := &pb.Link{Uri: "http://example.com/{1}/{0}"}
-	if _, err := s.Put(ctx, key, l); err != nil {
+	if _, err := s.Put(ctx, "existing", &pb.Link{Uri: "http://example.com/existing"}); err != nil {
 		t.Fatalf("Put failed: %v", err)
 	}
-
-	le, err := s.Get(ctx, key)
-	if err != nil {
-		t.Fatalf("Get failed: %v", err)
+	if _, err := s.db.ExecContext(ctx, `create trigger fail before insert on links
+		when new.path = 'boom' begin select raise(abort, 'injected fault'); end`); err != nil {
+		t.Fatalf("creating trigger failed: %v", err)
 	}
-	if got, want := le.RequiredPaths, requiredPaths(l); got != want {
-		t.Errorf("RequiredPaths = %d, want %d", got, want)
+	if _, _, _, err := s.ReplaceAll(ctx, map[string]*pb.Link{"boom": {Uri: "http://example.com/boom"}}); err == nil {
+		t.Fatal("ReplaceAll succeeded, want the injected fault")
 	}
-	if le.RequiredPaths != 2 {
-		t.Errorf("RequiredPaths = %d, want 2 for %q", le.RequiredPaths, l.Uri)
+	if le, err := s.Get(ctx, "existing"); err != nil || le == nil {
+		t.Errorf("Get(existing) after failed ReplaceAll = %v, %v; want it kept", le, err)
 	}
 }
 
-// The database file must survive being closed and reopened -- this is the
-// whole point of putting it on a volume.
-func TestSQLitePersistsAcrossReopen(t *testing.T) {
+func TestSQLiteMigratesManagedColumn(t *testing.T) {
 	ctx := context.Background()
 	path := filepath.Join(t.TempDir(), "links.db")
 
-	first, err := NewSQLiteStore(ctx, path)
-	if err != nil {
-		t.Fatalf("NewSQLiteStore failed: %v", err)
-	}
-	if _, err := first.Put(ctx, "persisted", &pb.Link{Uri: "http://example.com/persisted"}); err != nil {
-		t.Fatalf("Put failed: %v", err)
-	}
-	if err := first.Close(); err != nil {
-		t.Fatalf("Close failed: %v", err)
-	}
-
-	second, err := NewSQLiteStore(ctx, path)
+	// Create a database with the schema from before the managed column.
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
-		t.Fatalf("reopening store failed: %v", err)
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	for _, stmt := range []string{
+		"create table links (path text primary key, link text not null, segments integer not null)",
+		"insert into links (path, link, segments) values ('foo', 'http://example.com', 0)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
 	}
-	t.Cleanup(func() { second.Close() })
+	db.Close()
 
-	le, err := second.Get(ctx, "persisted")
+	s, err := NewSQLiteStore(ctx, path)
 	if err != nil {
-		t.Fatalf("Get after reopen failed: %v", err)
+		t.Fatalf("NewSQLiteStore failed: %v", err)
 	}
-	if le == nil {
-		t.Fatal("Get after reopen = nil, want the entry written before close")
+	t.Cleanup(func() { s.Close() })
+	if le, err := s.Get(ctx, "foo"); err != nil || le.GetLink().GetUri() != "http://example.com" || le.GetLink().GetManaged() {
+		t.Errorf("Get(foo) after migration = %v, %v; want the old, unmanaged link", le, err)
 	}
-	if got, want := le.Link.GetUri(), "http://example.com/persisted"; got != want {
-		t.Errorf("URI after reopen = %q, want %q", got, want)
+	if _, err := s.Put(ctx, "bar", &pb.Link{Uri: "http://example.com", Managed: true}); err != nil {
+		t.Fatalf("Put after migration failed: %v", err)
 	}
 }
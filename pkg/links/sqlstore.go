@@ -0,0 +1,405 @@
+package links
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// Statements on the links table, shared by every SQL database. They use ?
+// placeholders, which a dialect rewrites if its driver expects others.
+const (
+	sqlGet    = "select link, segments, managed from links where path=?"
+	sqlExists = "select 1 from links where path=?"
+	sqlPut    = `insert into links (path, link, segments, managed) values (?, ?, ?, ?)
+         on conflict (path) do update set link=excluded.link, segments=excluded.segments, managed=excluded.managed`
+	sqlDel  = "delete from links where path=?"
+	sqlList = "select path, link, segments, managed from links"
+	sqlKeys = "select path from links"
+	sqlPing = "select count(*) from (select 1 from links limit 1) as t"
+)
+
+// sqlTxAttempts bounds how many times a transaction is tried when the
+// database keeps aborting it in favor of concurrent ones.
+const sqlTxAttempts = 5
+
+// sqlDialect is what differs between the databases a sqlStore can use.
+type sqlDialect struct {
+	// schema creates the links table if it doesn't exist yet.
+	schema string
+	// columns lists the names of the links table's columns, and
+	// addManaged adds the managed column to a table created before it
+	// existed.
+	columns    string
+	addManaged string
+	// nonceSchema creates the table SQLNonceVerifier records nonces in.
+	nonceSchema string
+	// rebind rewrites a statement's ? placeholders, if the driver needs
+	// something else. Nil leaves them alone.
+	rebind func(string) string
+	// txOptions must make every transaction behave as if no other ran at
+	// the same time; the Store methods rely on it to read and then write
+	// without a concurrent writer getting in between.
+	txOptions *sql.TxOptions
+	// retryable reports whether a transaction failed only because of a
+	// concurrent one, and so is worth trying again. Nil means never.
+	retryable func(error) bool
+}
+
+func (d *sqlDialect) bind(query string) string {
+	if d.rebind == nil {
+		return query
+	}
+	return d.rebind(query)
+}
+
+// sqlStore implements Store on a SQL database. SQLiteStore and
+// PostgresStore are a sqlStore with the database's dialect.
+type sqlStore struct {
+	db *sql.DB
+	d  *sqlDialect
+	q  sqlQueries
+}
+
+// sqlQueries are the shared statements, as the dialect spells them.
+type sqlQueries struct {
+	get, exists, put, del, list, keys, ping string
+}
+
+// openSQLStore applies the schema to db, which it closes on failure.
+func openSQLStore(ctx context.Context, db *sql.DB, d *sqlDialect) (*sqlStore, error) {
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.Ping failed: %w", err)
+	}
+	s := &sqlStore{db: db, d: d, q: sqlQueries{
+		get:    d.bind(sqlGet),
+		exists: d.bind(sqlExists),
+		put:    d.bind(sqlPut),
+		del:    d.bind(sqlDel),
+		list:   d.bind(sqlList),
+		keys:   d.bind(sqlKeys),
+		ping:   d.bind(sqlPing),
+	}}
+	if _, err := db.ExecContext(ctx, d.schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("applying schema failed: %w", err)
+	}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating schema failed: %w", err)
+	}
+	return s, nil
+}
+
+// migrate adds the columns that the schema's create statement won't add to
+// an existing table.
+func (s *sqlStore) migrate(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, s.d.columns)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == "managed" {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = s.db.ExecContext(ctx, s.d.addManaged)
+	return err
+}
+
+// inTx runs fn in a transaction and commits it. If the database aborts the
+// transaction because of a concurrent one, fn runs again in a new one, so
+// it must not have effects outside tx besides setting its results.
+func (s *sqlStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := s.tryTx(ctx, fn)
+		if err == nil || attempt == sqlTxAttempts || s.d.retryable == nil || !s.d.retryable(err) {
+			return err
+		}
+	}
+}
+
+func (s *sqlStore) tryTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, s.d.txOptions)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Ping checks that the database is reachable and that the links table can
+// be queried.
+func (s *sqlStore) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return err
+	}
+	var n int
+	return s.db.QueryRowContext(ctx, s.q.ping).Scan(&n)
+}
+
+func (s *sqlStore) Get(ctx context.Context, key string) (*pb.LinkEntry, error) {
+	var link string
+	var segments int
+	var managed bool
+	if err := s.db.QueryRowContext(ctx, s.q.get, key).Scan(&link, &segments, &managed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &pb.LinkEntry{
+		Link:          &pb.Link{Uri: link, Managed: managed},
+		RequiredPaths: int32(segments),
+	}, nil
+}
+
+// Put upserts the link and reports whether it was created rather than
+// updated. An upsert can't report that portably, so the existence check and
+// the write share a transaction to keep the answer accurate under
+// concurrent writers.
+func (s *sqlStore) Put(ctx context.Context, key string, l *pb.Link) (bool, error) {
+	var created bool
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, s.q.exists, key).Scan(&exists)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		created = errors.Is(err, sql.ErrNoRows)
+		_, err = tx.ExecContext(ctx, s.q.put, key, l.Uri, requiredPaths(l), l.Managed)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// PutAll upserts every link in a single transaction, so a failure part way
+// through leaves the database untouched.
+func (s *sqlStore) PutAll(ctx context.Context, links map[string]*pb.Link) (int, int, error) {
+	var created, updated int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		created, updated, err = s.putAll(ctx, tx, links)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return created, updated, nil
+}
+
+// ReplaceAll deletes the keys links does not mention and upserts the rest,
+// all in one transaction.
+func (s *sqlStore) ReplaceAll(ctx context.Context, links map[string]*pb.Link) (int, int, int, error) {
+	var created, updated int
+	var stale []string
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		stale = nil
+		rows, err := tx.QueryContext(ctx, s.q.keys)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var k string
+			if err := rows.Scan(&k); err != nil {
+				rows.Close()
+				return err
+			}
+			if _, keep := links[k]; !keep {
+				stale = append(stale, k)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if _, err := tx.ExecContext(ctx, s.q.del, k); err != nil {
+				return fmt.Errorf("deleting %q: %w", k, err)
+			}
+		}
+
+		created, updated, err = s.putAll(ctx, tx, links)
+		return err
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return created, updated, len(stale), nil
+}
+
+// putAll upserts links within tx, reporting how many keys were created and
+// how many updated.
+func (s *sqlStore) putAll(ctx context.Context, tx *sql.Tx, links map[string]*pb.Link) (int, int, error) {
+	exists, err := tx.PrepareContext(ctx, s.q.exists)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer exists.Close()
+	put, err := tx.PrepareContext(ctx, s.q.put)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer put.Close()
+
+	var created, updated int
+	for k, l := range links {
+		var n int
+		err := exists.QueryRowContext(ctx, k).Scan(&n)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			created++
+		case err != nil:
+			return 0, 0, err
+		default:
+			updated++
+		}
+		if _, err := put.ExecContext(ctx, k, l.Uri, requiredPaths(l), l.Managed); err != nil {
+			return 0, 0, fmt.Errorf("writing %q: %w", k, err)
+		}
+	}
+	return created, updated, nil
+}
+
+// CompareAndSwap reads and writes in one transaction, which the dialect
+// isolates from concurrent writers, so nothing can change the row between
+// the comparison and the write.
+func (s *sqlStore) CompareAndSwap(ctx context.Context, key string, old, new *pb.Link) (bool, error) {
+	var swapped bool
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		swapped = false
+		var cur *pb.Link
+		var link string
+		var segments int
+		var managed bool
+		switch err := tx.QueryRowContext(ctx, s.q.get, key).Scan(&link, &segments, &managed); {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		default:
+			cur = &pb.Link{Uri: link, Managed: managed}
+		}
+		if (cur != nil) != (old != nil) || (cur != nil && !proto.Equal(cur, old)) {
+			return nil
+		}
+
+		var err error
+		if new == nil {
+			_, err = tx.ExecContext(ctx, s.q.del, key)
+		} else {
+			_, err = tx.ExecContext(ctx, s.q.put, key, new.Uri, requiredPaths(new), new.Managed)
+		}
+		swapped = err == nil
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (s *sqlStore) Delete(ctx context.Context, key string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, s.q.del, key)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *sqlStore) Rename(ctx context.Context, from, to string, overwrite, alias bool) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		var link string
+		var segments int
+		var managed bool
+		err := tx.QueryRowContext(ctx, s.q.get, from).Scan(&link, &segments, &managed)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			var n int
+			err := tx.QueryRowContext(ctx, s.q.exists, to).Scan(&n)
+			if err == nil {
+				return ErrExists
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.q.put, to, link, segments, managed); err != nil {
+			return err
+		}
+		if !alias {
+			if _, err := tx.ExecContext(ctx, s.q.del, from); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error {
+	rows, err := s.db.QueryContext(ctx, s.q.list)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var path string
+		var link string
+		var segments int
+		var managed bool
+		if err := rows.Scan(&path, &link, &segments, &managed); err != nil {
+			return err
+		}
+		visit(path, &pb.LinkEntry{
+			Link:          &pb.Link{Uri: link, Managed: managed},
+			RequiredPaths: int32(segments),
+		})
+	}
+	return rows.Err()
+}
+
+// numberedPlaceholders rewrites ? placeholders as $1, $2 and so on. None of
+// the statements have a ? anywhere else.
+func numberedPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -2,13 +2,45 @@ package links
 
 import (
 	"context"
+	"errors"
 
 	pb "jdtw.dev/links/proto/links"
 )
 
+var (
+	// ErrNotFound is returned by Store.Rename when there is no link to
+	// rename.
+	ErrNotFound = errors.New("link not found")
+	// ErrExists is returned by Store.Rename when the new key is taken.
+	ErrExists = errors.New("link already exists")
+	// ErrReadOnly is returned by the writes of a ReadOnlyStore.
+	ErrReadOnly = errors.New("links are read-only")
+)
+
+// Store holds the links the server serves. Implementations must be safe for
+// concurrent use, and must fail with the context's error, having changed
+// nothing, if it is done before they start. The storetest package checks an
+// implementation against all of this.
 type Store interface {
 	Get(ctx context.Context, k string) (*pb.LinkEntry, error)
 	Put(ctx context.Context, k string, l *pb.Link) (bool, error)
-	Delete(ctx context.Context, k string) error
+	// PutAll upserts every link in links atomically: either all of them
+	// are written or, on error, none are. It reports how many keys were
+	// created and how many updated.
+	PutAll(ctx context.Context, links map[string]*pb.Link) (created, updated int, err error)
+	// ReplaceAll atomically makes the store hold exactly links: like
+	// PutAll, plus deleting every key that links does not mention.
+	ReplaceAll(ctx context.Context, links map[string]*pb.Link) (created, updated, deleted int, err error)
+	// CompareAndSwap stores new under k, but only if the link currently
+	// stored there equals old. A nil old means k must not exist, and a nil
+	// new deletes k. It reports whether the swap happened.
+	CompareAndSwap(ctx context.Context, k string, old, new *pb.Link) (bool, error)
+	// Delete removes k, reporting whether it was there to remove.
+	Delete(ctx context.Context, k string) (bool, error)
+	// Rename atomically moves the link stored under from to to. It fails
+	// with ErrNotFound if from doesn't exist and, unless overwrite is set,
+	// with ErrExists if to does. With alias set, from keeps a copy of the
+	// link, so the old name goes on working.
+	Rename(ctx context.Context, from, to string, overwrite, alias bool) error
 	Visit(ctx context.Context, visit func(string, *pb.LinkEntry)) error
 }
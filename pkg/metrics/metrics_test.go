@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("requests_total", "Requests by code.", "code")
+	g := r.NewGauge("items", "Number of items.")
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+
+	c.Inc("200")
+	c.Inc("200")
+	c.Add(3, `we"ird\`)
+	g.Set(42)
+	h.Observe(0.05, "get")
+	h.Observe(0.5, "get")
+	h.Observe(5, "get")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", ct)
+	}
+
+	want := `# HELP requests_total Requests by code.
+# TYPE requests_total counter
+requests_total{code="200"} 2
+requests_total{code="we\"ird\\"} 3
+# HELP items Number of items.
+# TYPE items gauge
+items 42
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 1
+latency_seconds_bucket{op="get",le="1"} 2
+latency_seconds_bucket{op="get",le="+Inf"} 3
+latency_seconds_sum{op="get"} 5.55
+latency_seconds_count{op="get"} 3
+`
+	if got := rr.Body.String(); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValues(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c", "", "a", "b")
+	h := r.NewHistogram("h", "", DefBuckets)
+
+	c.Inc("x", "y")
+	if got := c.Value("x", "y"); got != 1 {
+		t.Errorf("Value(x, y) = %v, want 1", got)
+	}
+	if got := c.Value("y", "x"); got != 0 {
+		t.Errorf("Value(y, x) = %v, want 0", got)
+	}
+	h.Observe(1)
+	if got := h.Count(); got != 1 {
+		t.Errorf("Count() = %d, want 1", got)
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	c := NewRegistry().NewCounter("c", "", "a")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with no label values did not panic")
+		}
+	}()
+	c.Inc()
+}
@@ -0,0 +1,486 @@
+// Package storetest checks that a links.Store implementation behaves the
+// way the server relies on.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"jdtw.dev/links/pkg/links"
+	pb "jdtw.dev/links/proto/links"
+)
+
+// conformanceTests are the behaviors every Store must agree on. Each runs
+// against a new, empty store.
+var conformanceTests = []struct {
+	name string
+	test func(t *testing.T, s links.Store)
+}{
+	{"PutReportsCreatedVsUpdated", testPutReportsCreatedVsUpdated},
+	{"GetMissingKeyReturnsNil", testGetMissingKeyReturnsNil},
+	{"Delete", testDelete},
+	{"DeleteMissingKeyIsNoOp", testDeleteMissingKeyIsNoOp},
+	{"Visit", testVisit},
+	{"VisitSeesEveryLink", testVisitSeesEveryLink},
+	{"PutPersistsRequiredPaths", testPutPersistsRequiredPaths},
+	{"PutAllReportsCreatedVsUpdated", testPutAllReportsCreatedVsUpdated},
+	{"ReplaceAll", testReplaceAll},
+	{"StoresManaged", testStoresManaged},
+	{"CompareAndSwap", testCompareAndSwap},
+	{"Rename", testRename},
+	{"ConcurrentPutGet", testConcurrentPutGet},
+	{"ConcurrentPutsCreateOnce", testConcurrentPutsCreateOnce},
+	{"CanceledContext", testCanceledContext},
+}
+
+// RunConformance runs the Store conformance tests, each as a subtest of t,
+// against a store returned by newStore. newStore is called once per test,
+// with that test's t, and must return a new, empty store. It can fail or
+// skip the test through t, and register cleanups on it that run as soon as
+// the test is done. (The factory takes t, rather than being a plain
+// func() links.Store, because with cleanups registered on the outer t every
+// store would stay open until the whole suite had run, and a factory
+// failing from inside a subtest would have no t of its own to fail.)
+func RunConformance(t *testing.T, newStore func(t *testing.T) links.Store) {
+	for _, ct := range conformanceTests {
+		t.Run(ct.name, func(t *testing.T) {
+			ct.test(t, newStore(t))
+		})
+	}
+}
+
+func testPutReportsCreatedVsUpdated(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	const key = "createdvsupdated"
+
+	created, err := s.Put(ctx, key, &pb.Link{Uri: "http://example.com/first"})
+	if err != nil {
+		t.Fatalf("Put (insert) failed: %v", err)
+	}
+	if !created {
+		t.Errorf("Put (insert) reported created=false, want true")
+	}
+
+	created, err = s.Put(ctx, key, &pb.Link{Uri: "http://example.com/second"})
+	if err != nil {
+		t.Fatalf("Put (update) failed: %v", err)
+	}
+	if created {
+		t.Errorf("Put (update) reported created=true, want false")
+	}
+
+	le, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got, want := le.Link.GetUri(), "http://example.com/second"; got != want {
+		t.Errorf("Get(%s) URI = %q, want %q", key, got, want)
+	}
+}
+
+func testGetMissingKeyReturnsNil(t *testing.T, s links.Store) {
+	le, err := s.Get(context.Background(), "doesnotexist")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if le != nil {
+		t.Errorf("Get(missing) = %v, want nil", le)
+	}
+}
+
+func testDelete(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	const key = "delete"
+
+	if _, err := s.Put(ctx, key, &pb.Link{Uri: "http://example.com"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if deleted, err := s.Delete(ctx, key); err != nil || !deleted {
+		t.Fatalf("Delete = %t, %v; want true", deleted, err)
+	}
+	le, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get after delete failed: %v", err)
+	}
+	if le != nil {
+		t.Errorf("Get after delete = %v, want nil", le)
+	}
+}
+
+// Deleting a key that was never present should be a no-op that reports
+// nothing was deleted.
+func testDeleteMissingKeyIsNoOp(t *testing.T, s links.Store) {
+	if deleted, err := s.Delete(context.Background(), "neverexisted"); err != nil || deleted {
+		t.Errorf("Delete(missing) = %t, %v; want false", deleted, err)
+	}
+}
+
+func testVisit(t *testing.T, s links.Store) {
+	ctx := context.Background()
+
+	want := map[string]string{
+		"one":   "http://example.com/one",
+		"two":   "http://example.com/two",
+		"three": "http://example.com/three",
+	}
+	for k, uri := range want {
+		if _, err := s.Put(ctx, k, &pb.Link{Uri: uri}); err != nil {
+			t.Fatalf("Put(%s) failed: %v", k, err)
+		}
+	}
+
+	got := map[string]string{}
+	if err := s.Visit(ctx, func(k string, le *pb.LinkEntry) {
+		got[k] = le.Link.GetUri()
+	}); err != nil {
+		t.Fatalf("Visit failed: %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("Visit saw %d entries, want %d", len(got), len(want))
+	}
+	for k, wantURI := range want {
+		if got[k] != wantURI {
+			t.Errorf("Visit(%s) URI = %q, want %q", k, got[k], wantURI)
+		}
+	}
+}
+
+// Put must persist the computed RequiredPaths so that {n} substitution keeps
+// working after a restart.
+func testPutPersistsRequiredPaths(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	const key = "subst"
+
+	l := &pb.Link{Uri: "http://example.com/{1}/{0}"}
+	if _, err := s.Put(ctx, key, l); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	le, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if le.RequiredPaths != 2 {
+		t.Errorf("RequiredPaths = %d, want 2 for %q", le.RequiredPaths, l.Uri)
+	}
+}
+
+func testPutAllReportsCreatedVsUpdated(t *testing.T, s links.Store) {
+	ctx := context.Background()
+
+	if _, err := s.Put(ctx, "existing", &pb.Link{Uri: "http://example.com/old"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	created, updated, err := s.PutAll(ctx, map[string]*pb.Link{
+		"existing": {Uri: "http://example.com/new"},
+		"one":      {Uri: "http://example.com/one"},
+		"two":      {Uri: "http://example.com/{1}/{0}"},
+	})
+	if err != nil {
+		t.Fatalf("PutAll failed: %v", err)
+	}
+	if created != 2 || updated != 1 {
+		t.Errorf("PutAll = %d created, %d updated; want 2, 1", created, updated)
+	}
+	le, err := s.Get(ctx, "two")
+	if err != nil || le == nil {
+		t.Fatalf("Get(two) = %v, %v", le, err)
+	}
+	if le.RequiredPaths != 2 {
+		t.Errorf("RequiredPaths = %d, want 2", le.RequiredPaths)
+	}
+}
+
+func testReplaceAll(t *testing.T, s links.Store) {
+	ctx := context.Background()
+
+	for k, uri := range map[string]string{
+		"stale": "http://example.com/stale",
+		"kept":  "http://example.com/old",
+	} {
+		if _, err := s.Put(ctx, k, &pb.Link{Uri: uri}); err != nil {
+			t.Fatalf("Put(%s) failed: %v", k, err)
+		}
+	}
+	created, updated, deleted, err := s.ReplaceAll(ctx, map[string]*pb.Link{
+		"kept":  {Uri: "http://example.com/new"},
+		"fresh": {Uri: "http://example.com/fresh"},
+	})
+	if err != nil {
+		t.Fatalf("ReplaceAll failed: %v", err)
+	}
+	if created != 1 || updated != 1 || deleted != 1 {
+		t.Errorf("ReplaceAll = %d created, %d updated, %d deleted; want 1, 1, 1", created, updated, deleted)
+	}
+
+	got := map[string]string{}
+	if err := s.Visit(ctx, func(k string, le *pb.LinkEntry) { got[k] = le.Link.GetUri() }); err != nil {
+		t.Fatalf("Visit failed: %v", err)
+	}
+	want := map[string]string{"kept": "http://example.com/new", "fresh": "http://example.com/fresh"}
+	if len(got) != len(want) || got["kept"] != want["kept"] || got["fresh"] != want["fresh"] {
+		t.Errorf("after ReplaceAll the store holds %v, want %v", got, want)
+	}
+}
+
+func testStoresManaged(t *testing.T, s links.Store) {
+	ctx := context.Background()
+
+	if _, err := s.Put(ctx, "foo", &pb.Link{Uri: "http://example.com", Managed: true}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	le, err := s.Get(ctx, "foo")
+	if err != nil || !le.GetLink().GetManaged() {
+		t.Errorf("Get(foo) = %v, %v; want a managed link", le, err)
+	}
+	if _, err := s.Put(ctx, "foo", &pb.Link{Uri: "http://example.com"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if le, err := s.Get(ctx, "foo"); err != nil || le.GetLink().GetManaged() {
+		t.Errorf("Get(foo) after unmanaged Put = %v, %v; want it unmanaged", le, err)
+	}
+}
+
+func testCompareAndSwap(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	v1 := &pb.Link{Uri: "http://example.com/v1"}
+	v2 := &pb.Link{Uri: "http://example.com/v2"}
+
+	steps := []struct {
+		desc     string
+		old, new *pb.Link
+		want     bool
+		wantURI  string
+	}{
+		{"create", nil, v1, true, v1.Uri},
+		{"create existing", nil, v2, false, v1.Uri},
+		{"update stale", v2, v2, false, v1.Uri},
+		{"update", v1, v2, true, v2.Uri},
+		{"update managed mismatch", &pb.Link{Uri: v2.Uri, Managed: true}, v1, false, v2.Uri},
+		{"delete stale", v1, nil, false, v2.Uri},
+		{"delete", v2, nil, true, ""},
+		{"delete missing", v2, nil, false, ""},
+	}
+	for _, st := range steps {
+		swapped, err := s.CompareAndSwap(ctx, "foo", st.old, st.new)
+		if err != nil {
+			t.Fatalf("%s: CompareAndSwap failed: %v", st.desc, err)
+		}
+		if swapped != st.want {
+			t.Errorf("%s: CompareAndSwap = %t, want %t", st.desc, swapped, st.want)
+		}
+		le, err := s.Get(ctx, "foo")
+		if err != nil {
+			t.Fatalf("%s: Get failed: %v", st.desc, err)
+		}
+		if got := le.GetLink().GetUri(); got != st.wantURI {
+			t.Errorf("%s: Get(foo) = %q, want %q", st.desc, got, st.wantURI)
+		}
+	}
+}
+
+func testRename(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	uri := func(k string) string {
+		t.Helper()
+		le, err := s.Get(ctx, k)
+		if err != nil {
+			t.Fatalf("Get(%s) failed: %v", k, err)
+		}
+		return le.GetLink().GetUri()
+	}
+	s.Put(ctx, "a", &pb.Link{Uri: "http://a/{0}", Managed: true})
+	s.Put(ctx, "b", &pb.Link{Uri: "http://b"})
+
+	if err := s.Rename(ctx, "a", "c", false, false); err != nil {
+		t.Fatalf("Rename(a, c) failed: %v", err)
+	}
+	if le, _ := s.Get(ctx, "c"); le.GetLink().GetUri() != "http://a/{0}" || !le.GetLink().GetManaged() || le.GetRequiredPaths() != 1 {
+		t.Errorf("Get(c) after rename = %v, want a's entry unchanged", le)
+	}
+	if got := uri("a"); got != "" {
+		t.Errorf("Get(a) after rename = %q, want nothing", got)
+	}
+
+	if err := s.Rename(ctx, "a", "d", false, false); !errors.Is(err, links.ErrNotFound) {
+		t.Errorf("Rename of a missing link returned %v, want %v", err, links.ErrNotFound)
+	}
+	if err := s.Rename(ctx, "c", "b", false, false); !errors.Is(err, links.ErrExists) {
+		t.Errorf("Rename onto a taken key returned %v, want %v", err, links.ErrExists)
+	}
+	if got := uri("b"); got != "http://b" {
+		t.Errorf("Get(b) after a failed rename = %q, want it untouched", got)
+	}
+
+	if err := s.Rename(ctx, "c", "b", true, true); err != nil {
+		t.Fatalf("Rename(c, b) with overwrite and alias failed: %v", err)
+	}
+	if got, old := uri("b"), uri("c"); got != "http://a/{0}" || old != "http://a/{0}" {
+		t.Errorf("after aliased rename b = %q and c = %q, want both http://a/{0}", got, old)
+	}
+}
+
+// Visit must see every link exactly once, however many there are.
+func testVisitSeesEveryLink(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	const n = 500
+
+	want := make(map[string]*pb.Link, n)
+	for i := range n {
+		want[fmt.Sprint("link", i)] = &pb.Link{Uri: fmt.Sprintf("http://example.com/%d/{0}", i)}
+	}
+	if _, _, err := s.PutAll(ctx, want); err != nil {
+		t.Fatalf("PutAll failed: %v", err)
+	}
+
+	seen := make(map[string]int, n)
+	if err := s.Visit(ctx, func(k string, le *pb.LinkEntry) {
+		seen[k]++
+		if l, ok := want[k]; !ok || le.GetLink().GetUri() != l.GetUri() || le.GetRequiredPaths() != 1 {
+			t.Errorf("Visit(%s) = %v, want %v with one required path", k, le, l)
+		}
+	}); err != nil {
+		t.Fatalf("Visit failed: %v", err)
+	}
+	if len(seen) != n {
+		t.Errorf("Visit saw %d links, want %d", len(seen), n)
+	}
+	for k, times := range seen {
+		if times != 1 {
+			t.Errorf("Visit saw %s %d times, want once", k, times)
+		}
+	}
+}
+
+// Writers and readers working at once must neither fail nor lose writes,
+// and each writer must read back what it wrote.
+func testConcurrentPutGet(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	const writers, keys = 8, 20
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Go(func() {
+			for i := range keys {
+				k := fmt.Sprintf("w%dk%d", w, i)
+				uri := "http://example.com/" + k
+				if _, err := s.Put(ctx, k, &pb.Link{Uri: uri}); err != nil {
+					t.Errorf("Put(%s) failed: %v", k, err)
+					return
+				}
+				if le, err := s.Get(ctx, k); err != nil || le.GetLink().GetUri() != uri {
+					t.Errorf("Get(%s) = %v, %v; want %s", k, le, err, uri)
+				}
+				// Read another writer's keys, which may or may not be
+				// there yet.
+				if _, err := s.Get(ctx, fmt.Sprintf("w%dk%d", (w+1)%writers, i)); err != nil {
+					t.Errorf("Get failed: %v", err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	n := 0
+	if err := s.Visit(ctx, func(string, *pb.LinkEntry) { n++ }); err != nil {
+		t.Fatalf("Visit failed: %v", err)
+	}
+	if n != writers*keys {
+		t.Errorf("store holds %d links after concurrent Puts, want %d", n, writers*keys)
+	}
+}
+
+// Puts racing to store a new key must agree that exactly one of them
+// created it.
+func testConcurrentPutsCreateOnce(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	const writers = 4
+
+	var created sync.Map
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Go(func() {
+			c, err := s.Put(ctx, "contended", &pb.Link{Uri: fmt.Sprint("http://example.com/", w)})
+			if err != nil {
+				t.Errorf("Put failed: %v", err)
+				return
+			}
+			created.Store(w, c)
+		})
+	}
+	wg.Wait()
+
+	n := 0
+	created.Range(func(_, c any) bool {
+		if c.(bool) {
+			n++
+		}
+		return true
+	})
+	if n != 1 {
+		t.Errorf("%d concurrent Puts reported creating the key, want 1", n)
+	}
+}
+
+// Every method must fail with the context's error once it is canceled,
+// and a write must leave the store as it was.
+func testCanceledContext(t *testing.T, s links.Store) {
+	ctx := context.Background()
+	foo := &pb.Link{Uri: "http://example.com/foo"}
+	if _, err := s.Put(ctx, "foo", foo); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	bar := &pb.Link{Uri: "http://example.com/bar"}
+	calls := map[string]func() error{
+		"Get": func() error {
+			_, err := s.Get(canceled, "foo")
+			return err
+		},
+		"Put": func() error {
+			_, err := s.Put(canceled, "foo", bar)
+			return err
+		},
+		"PutAll": func() error {
+			_, _, err := s.PutAll(canceled, map[string]*pb.Link{"bar": bar})
+			return err
+		},
+		"ReplaceAll": func() error {
+			_, _, _, err := s.ReplaceAll(canceled, map[string]*pb.Link{"bar": bar})
+			return err
+		},
+		"CompareAndSwap": func() error {
+			_, err := s.CompareAndSwap(canceled, "foo", foo, bar)
+			return err
+		},
+		"Delete": func() error {
+			_, err := s.Delete(canceled, "foo")
+			return err
+		},
+		"Rename": func() error {
+			return s.Rename(canceled, "foo", "bar", false, false)
+		},
+		"Visit": func() error {
+			return s.Visit(canceled, func(string, *pb.LinkEntry) {})
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s with a canceled context returned %v, want %v", name, err, context.Canceled)
+		}
+	}
+
+	got := map[string]string{}
+	if err := s.Visit(ctx, func(k string, le *pb.LinkEntry) { got[k] = le.GetLink().GetUri() }); err != nil {
+		t.Fatalf("Visit failed: %v", err)
+	}
+	if len(got) != 1 || got["foo"] != foo.GetUri() {
+		t.Errorf("after canceled writes the store holds %v, want only foo -> %s", got, foo.GetUri())
+	}
+}
@@ -9,6 +9,7 @@ package links
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,8 +23,11 @@ const (
 )
 
 type Link struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Uri           string                 `protobuf:"bytes,1,opt,name=uri,proto3" json:"uri,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Uri   string                 `protobuf:"bytes,1,opt,name=uri,proto3" json:"uri,omitempty"`
+	// Set on links written by `client sync`. A sync only deletes links it
+	// manages, so links created any other way are left alone.
+	Managed       bool `protobuf:"varint,2,opt,name=managed,proto3" json:"managed,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,6 +69,13 @@ func (x *Link) GetUri() string {
 	return ""
 }
 
+func (x *Link) GetManaged() bool {
+	if x != nil {
+		return x.Managed
+	}
+	return false
+}
+
 type LinkEntry struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	Link  *Link                  `protobuf:"bytes,1,opt,name=link,proto3" json:"link,omitempty"`
@@ -163,13 +174,745 @@ func (x *Links) GetLinks() map[string]*Link {
 	return nil
 }
 
+// LinkChange describes what an import does to a single key.
+type LinkChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Key   string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// The link currently stored under key; unset if the import adds it.
+	Old *Link `protobuf:"bytes,2,opt,name=old,proto3" json:"old,omitempty"`
+	// The link the import stores under key; unset if the import deletes it.
+	New           *Link `protobuf:"bytes,3,opt,name=new,proto3" json:"new,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinkChange) Reset() {
+	*x = LinkChange{}
+	mi := &file_proto_links_links_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkChange) ProtoMessage() {}
+
+func (x *LinkChange) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkChange.ProtoReflect.Descriptor instead.
+func (*LinkChange) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LinkChange) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *LinkChange) GetOld() *Link {
+	if x != nil {
+		return x.Old
+	}
+	return nil
+}
+
+func (x *LinkChange) GetNew() *Link {
+	if x != nil {
+		return x.New
+	}
+	return nil
+}
+
+// LinksDiff is the effect of an import on the store, sorted by key within
+// each list. Links that an additive import does not mention appear nowhere.
+type LinksDiff struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Added         []*LinkChange          `protobuf:"bytes,1,rep,name=added,proto3" json:"added,omitempty"`
+	Updated       []*LinkChange          `protobuf:"bytes,2,rep,name=updated,proto3" json:"updated,omitempty"`
+	Deleted       []*LinkChange          `protobuf:"bytes,3,rep,name=deleted,proto3" json:"deleted,omitempty"`
+	Unchanged     []*LinkChange          `protobuf:"bytes,4,rep,name=unchanged,proto3" json:"unchanged,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LinksDiff) Reset() {
+	*x = LinksDiff{}
+	mi := &file_proto_links_links_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinksDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinksDiff) ProtoMessage() {}
+
+func (x *LinksDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinksDiff.ProtoReflect.Descriptor instead.
+func (*LinksDiff) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LinksDiff) GetAdded() []*LinkChange {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *LinksDiff) GetUpdated() []*LinkChange {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *LinksDiff) GetDeleted() []*LinkChange {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+func (x *LinksDiff) GetUnchanged() []*LinkChange {
+	if x != nil {
+		return x.Unchanged
+	}
+	return nil
+}
+
+// CreateLinkRequest asks the server to create a link under a key that isn't
+// taken yet.
+type CreateLinkRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Link  *Link                  `protobuf:"bytes,1,opt,name=link,proto3" json:"link,omitempty"`
+	// The key to create. If empty, the server generates a short one.
+	Key           string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateLinkRequest) Reset() {
+	*x = CreateLinkRequest{}
+	mi := &file_proto_links_links_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateLinkRequest) ProtoMessage() {}
+
+func (x *CreateLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateLinkRequest.ProtoReflect.Descriptor instead.
+func (*CreateLinkRequest) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateLinkRequest) GetLink() *Link {
+	if x != nil {
+		return x.Link
+	}
+	return nil
+}
+
+func (x *CreateLinkRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type CreateLinkResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The key the link was created under, normalized.
+	Key           string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateLinkResponse) Reset() {
+	*x = CreateLinkResponse{}
+	mi := &file_proto_links_links_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateLinkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateLinkResponse) ProtoMessage() {}
+
+func (x *CreateLinkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateLinkResponse.ProtoReflect.Descriptor instead.
+func (*CreateLinkResponse) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateLinkResponse) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+// WhoAmIResponse is who the server took a request to be from, to debug keys
+// and clocks.
+type WhoAmIResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The subject of the key that signed the request's token.
+	Subject string `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	// The server's clock when it handled the request.
+	ServerTime    *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=server_time,json=serverTime,proto3" json:"server_time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WhoAmIResponse) Reset() {
+	*x = WhoAmIResponse{}
+	mi := &file_proto_links_links_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WhoAmIResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WhoAmIResponse) ProtoMessage() {}
+
+func (x *WhoAmIResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WhoAmIResponse.ProtoReflect.Descriptor instead.
+func (*WhoAmIResponse) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WhoAmIResponse) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *WhoAmIResponse) GetServerTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ServerTime
+	}
+	return nil
+}
+
+// ReadOnlyStatus is whether the server refuses writes, as reported by and
+// set through /api/readonly.
+type ReadOnlyStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReadOnly      bool                   `protobuf:"varint,1,opt,name=read_only,json=readOnly,proto3" json:"read_only,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReadOnlyStatus) Reset() {
+	*x = ReadOnlyStatus{}
+	mi := &file_proto_links_links_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReadOnlyStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReadOnlyStatus) ProtoMessage() {}
+
+func (x *ReadOnlyStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReadOnlyStatus.ProtoReflect.Descriptor instead.
+func (*ReadOnlyStatus) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ReadOnlyStatus) GetReadOnly() bool {
+	if x != nil {
+		return x.ReadOnly
+	}
+	return false
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_proto_links_links_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *GetRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type PutRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Link          *Link                  `protobuf:"bytes,2,opt,name=link,proto3" json:"link,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PutRequest) Reset() {
+	*x = PutRequest{}
+	mi := &file_proto_links_links_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PutRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutRequest) ProtoMessage() {}
+
+func (x *PutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutRequest.ProtoReflect.Descriptor instead.
+func (*PutRequest) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PutRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *PutRequest) GetLink() *Link {
+	if x != nil {
+		return x.Link
+	}
+	return nil
+}
+
+type PutResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Whether the link was created rather than updated.
+	Created       bool `protobuf:"varint,1,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PutResponse) Reset() {
+	*x = PutResponse{}
+	mi := &file_proto_links_links_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutResponse) ProtoMessage() {}
+
+func (x *PutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutResponse.ProtoReflect.Descriptor instead.
+func (*PutResponse) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PutResponse) GetCreated() bool {
+	if x != nil {
+		return x.Created
+	}
+	return false
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	MissingOk     bool                   `protobuf:"varint,2,opt,name=missing_ok,json=missingOk,proto3" json:"missing_ok,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_proto_links_links_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeleteRequest) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetMissingOk() bool {
+	if x != nil {
+		return x.MissingOk
+	}
+	return false
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_proto_links_links_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{13}
+}
+
+type ListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_proto_links_links_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{14}
+}
+
+type BulkPutRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Links *Links                 `protobuf:"bytes,1,opt,name=links,proto3" json:"links,omitempty"`
+	// Delete links that links does not mention, instead of leaving them be.
+	Replace bool `protobuf:"varint,2,opt,name=replace,proto3" json:"replace,omitempty"`
+	// Report what the import would do without doing it.
+	DryRun        bool `protobuf:"varint,3,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkPutRequest) Reset() {
+	*x = BulkPutRequest{}
+	mi := &file_proto_links_links_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkPutRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkPutRequest) ProtoMessage() {}
+
+func (x *BulkPutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkPutRequest.ProtoReflect.Descriptor instead.
+func (*BulkPutRequest) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BulkPutRequest) GetLinks() *Links {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *BulkPutRequest) GetReplace() bool {
+	if x != nil {
+		return x.Replace
+	}
+	return false
+}
+
+func (x *BulkPutRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type BulkPutResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Created int32                  `protobuf:"varint,1,opt,name=created,proto3" json:"created,omitempty"`
+	Updated int32                  `protobuf:"varint,2,opt,name=updated,proto3" json:"updated,omitempty"`
+	Deleted int32                  `protobuf:"varint,3,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	// For a dry run, what the import would do. Unset otherwise.
+	Diff          *LinksDiff `protobuf:"bytes,4,opt,name=diff,proto3" json:"diff,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BulkPutResponse) Reset() {
+	*x = BulkPutResponse{}
+	mi := &file_proto_links_links_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BulkPutResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BulkPutResponse) ProtoMessage() {}
+
+func (x *BulkPutResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_links_links_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BulkPutResponse.ProtoReflect.Descriptor instead.
+func (*BulkPutResponse) Descriptor() ([]byte, []int) {
+	return file_proto_links_links_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *BulkPutResponse) GetCreated() int32 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *BulkPutResponse) GetUpdated() int32 {
+	if x != nil {
+		return x.Updated
+	}
+	return 0
+}
+
+func (x *BulkPutResponse) GetDeleted() int32 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+func (x *BulkPutResponse) GetDiff() *LinksDiff {
+	if x != nil {
+		return x.Diff
+	}
+	return nil
+}
+
 var File_proto_links_links_proto protoreflect.FileDescriptor
 
 const file_proto_links_links_proto_rawDesc = "" +
 	"\n" +
-	"\x17proto/links/links.proto\x12\x05links\"\x18\n" +
+	"\x17proto/links/links.proto\x12\x05links\x1a\x1fgoogle/protobuf/timestamp.proto\"2\n" +
 	"\x04Link\x12\x10\n" +
-	"\x03uri\x18\x01 \x01(\tR\x03uri\"S\n" +
+	"\x03uri\x18\x01 \x01(\tR\x03uri\x12\x18\n" +
+	"\amanaged\x18\x02 \x01(\bR\amanaged\"S\n" +
 	"\tLinkEntry\x12\x1f\n" +
 	"\x04link\x18\x01 \x01(\v2\v.links.LinkR\x04link\x12%\n" +
 	"\x0erequired_paths\x18\x02 \x01(\x05R\rrequiredPaths\"}\n" +
@@ -178,7 +921,58 @@ const file_proto_links_links_proto_rawDesc = "" +
 	"\n" +
 	"LinksEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12!\n" +
-	"\x05value\x18\x02 \x01(\v2\v.links.LinkR\x05value:\x028\x01B\x1cZ\x1ajdtw.dev/links/proto/linksb\x06proto3"
+	"\x05value\x18\x02 \x01(\v2\v.links.LinkR\x05value:\x028\x01\"\\\n" +
+	"\n" +
+	"LinkChange\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1d\n" +
+	"\x03old\x18\x02 \x01(\v2\v.links.LinkR\x03old\x12\x1d\n" +
+	"\x03new\x18\x03 \x01(\v2\v.links.LinkR\x03new\"\xbf\x01\n" +
+	"\tLinksDiff\x12'\n" +
+	"\x05added\x18\x01 \x03(\v2\x11.links.LinkChangeR\x05added\x12+\n" +
+	"\aupdated\x18\x02 \x03(\v2\x11.links.LinkChangeR\aupdated\x12+\n" +
+	"\adeleted\x18\x03 \x03(\v2\x11.links.LinkChangeR\adeleted\x12/\n" +
+	"\tunchanged\x18\x04 \x03(\v2\x11.links.LinkChangeR\tunchanged\"F\n" +
+	"\x11CreateLinkRequest\x12\x1f\n" +
+	"\x04link\x18\x01 \x01(\v2\v.links.LinkR\x04link\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\tR\x03key\"&\n" +
+	"\x12CreateLinkResponse\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"g\n" +
+	"\x0eWhoAmIResponse\x12\x18\n" +
+	"\asubject\x18\x01 \x01(\tR\asubject\x12;\n" +
+	"\vserver_time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"serverTime\"-\n" +
+	"\x0eReadOnlyStatus\x12\x1b\n" +
+	"\tread_only\x18\x01 \x01(\bR\breadOnly\"\x1e\n" +
+	"\n" +
+	"GetRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\"?\n" +
+	"\n" +
+	"PutRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1f\n" +
+	"\x04link\x18\x02 \x01(\v2\v.links.LinkR\x04link\"'\n" +
+	"\vPutResponse\x12\x18\n" +
+	"\acreated\x18\x01 \x01(\bR\acreated\"@\n" +
+	"\rDeleteRequest\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x1d\n" +
+	"\n" +
+	"missing_ok\x18\x02 \x01(\bR\tmissingOk\"\x10\n" +
+	"\x0eDeleteResponse\"\r\n" +
+	"\vListRequest\"g\n" +
+	"\x0eBulkPutRequest\x12\"\n" +
+	"\x05links\x18\x01 \x01(\v2\f.links.LinksR\x05links\x12\x18\n" +
+	"\areplace\x18\x02 \x01(\bR\areplace\x12\x17\n" +
+	"\adry_run\x18\x03 \x01(\bR\x06dryRun\"\x85\x01\n" +
+	"\x0fBulkPutResponse\x12\x18\n" +
+	"\acreated\x18\x01 \x01(\x05R\acreated\x12\x18\n" +
+	"\aupdated\x18\x02 \x01(\x05R\aupdated\x12\x18\n" +
+	"\adeleted\x18\x03 \x01(\x05R\adeleted\x12$\n" +
+	"\x04diff\x18\x04 \x01(\v2\x10.links.LinksDiffR\x04diff2\xfe\x01\n" +
+	"\fLinksService\x12%\n" +
+	"\x03Get\x12\x11.links.GetRequest\x1a\v.links.Link\x12,\n" +
+	"\x03Put\x12\x11.links.PutRequest\x1a\x12.links.PutResponse\x125\n" +
+	"\x06Delete\x12\x14.links.DeleteRequest\x1a\x15.links.DeleteResponse\x12(\n" +
+	"\x04List\x12\x12.links.ListRequest\x1a\f.links.Links\x128\n" +
+	"\aBulkPut\x12\x15.links.BulkPutRequest\x1a\x16.links.BulkPutResponseB\x1cZ\x1ajdtw.dev/links/proto/linksb\x06proto3"
 
 var (
 	file_proto_links_links_proto_rawDescOnce sync.Once
@@ -192,22 +986,58 @@ func file_proto_links_links_proto_rawDescGZIP() []byte {
 	return file_proto_links_links_proto_rawDescData
 }
 
-var file_proto_links_links_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_links_links_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
 var file_proto_links_links_proto_goTypes = []any{
-	(*Link)(nil),      // 0: links.Link
-	(*LinkEntry)(nil), // 1: links.LinkEntry
-	(*Links)(nil),     // 2: links.Links
-	nil,               // 3: links.Links.LinksEntry
+	(*Link)(nil),                  // 0: links.Link
+	(*LinkEntry)(nil),             // 1: links.LinkEntry
+	(*Links)(nil),                 // 2: links.Links
+	(*LinkChange)(nil),            // 3: links.LinkChange
+	(*LinksDiff)(nil),             // 4: links.LinksDiff
+	(*CreateLinkRequest)(nil),     // 5: links.CreateLinkRequest
+	(*CreateLinkResponse)(nil),    // 6: links.CreateLinkResponse
+	(*WhoAmIResponse)(nil),        // 7: links.WhoAmIResponse
+	(*ReadOnlyStatus)(nil),        // 8: links.ReadOnlyStatus
+	(*GetRequest)(nil),            // 9: links.GetRequest
+	(*PutRequest)(nil),            // 10: links.PutRequest
+	(*PutResponse)(nil),           // 11: links.PutResponse
+	(*DeleteRequest)(nil),         // 12: links.DeleteRequest
+	(*DeleteResponse)(nil),        // 13: links.DeleteResponse
+	(*ListRequest)(nil),           // 14: links.ListRequest
+	(*BulkPutRequest)(nil),        // 15: links.BulkPutRequest
+	(*BulkPutResponse)(nil),       // 16: links.BulkPutResponse
+	nil,                           // 17: links.Links.LinksEntry
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
 }
 var file_proto_links_links_proto_depIdxs = []int32{
-	0, // 0: links.LinkEntry.link:type_name -> links.Link
-	3, // 1: links.Links.links:type_name -> links.Links.LinksEntry
-	0, // 2: links.Links.LinksEntry.value:type_name -> links.Link
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	0,  // 0: links.LinkEntry.link:type_name -> links.Link
+	17, // 1: links.Links.links:type_name -> links.Links.LinksEntry
+	0,  // 2: links.LinkChange.old:type_name -> links.Link
+	0,  // 3: links.LinkChange.new:type_name -> links.Link
+	3,  // 4: links.LinksDiff.added:type_name -> links.LinkChange
+	3,  // 5: links.LinksDiff.updated:type_name -> links.LinkChange
+	3,  // 6: links.LinksDiff.deleted:type_name -> links.LinkChange
+	3,  // 7: links.LinksDiff.unchanged:type_name -> links.LinkChange
+	0,  // 8: links.CreateLinkRequest.link:type_name -> links.Link
+	18, // 9: links.WhoAmIResponse.server_time:type_name -> google.protobuf.Timestamp
+	0,  // 10: links.PutRequest.link:type_name -> links.Link
+	2,  // 11: links.BulkPutRequest.links:type_name -> links.Links
+	4,  // 12: links.BulkPutResponse.diff:type_name -> links.LinksDiff
+	0,  // 13: links.Links.LinksEntry.value:type_name -> links.Link
+	9,  // 14: links.LinksService.Get:input_type -> links.GetRequest
+	10, // 15: links.LinksService.Put:input_type -> links.PutRequest
+	12, // 16: links.LinksService.Delete:input_type -> links.DeleteRequest
+	14, // 17: links.LinksService.List:input_type -> links.ListRequest
+	15, // 18: links.LinksService.BulkPut:input_type -> links.BulkPutRequest
+	0,  // 19: links.LinksService.Get:output_type -> links.Link
+	11, // 20: links.LinksService.Put:output_type -> links.PutResponse
+	13, // 21: links.LinksService.Delete:output_type -> links.DeleteResponse
+	2,  // 22: links.LinksService.List:output_type -> links.Links
+	16, // 23: links.LinksService.BulkPut:output_type -> links.BulkPutResponse
+	19, // [19:24] is the sub-list for method output_type
+	14, // [14:19] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_proto_links_links_proto_init() }
@@ -221,9 +1051,9 @@ func file_proto_links_links_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_links_links_proto_rawDesc), len(file_proto_links_links_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   18,
 			NumExtensions: 0,
-			NumServices:   0,
+			NumServices:   1,
 		},
 		GoTypes:           file_proto_links_links_proto_goTypes,
 		DependencyIndexes: file_proto_links_links_proto_depIdxs,
@@ -0,0 +1,232 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: proto/links/links.proto
+
+package linksconnect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	links "jdtw.dev/links/proto/links"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// LinksServiceName is the fully-qualified name of the LinksService service.
+	LinksServiceName = "links.LinksService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// LinksServiceGetProcedure is the fully-qualified name of the LinksService's Get RPC.
+	LinksServiceGetProcedure = "/links.LinksService/Get"
+	// LinksServicePutProcedure is the fully-qualified name of the LinksService's Put RPC.
+	LinksServicePutProcedure = "/links.LinksService/Put"
+	// LinksServiceDeleteProcedure is the fully-qualified name of the LinksService's Delete RPC.
+	LinksServiceDeleteProcedure = "/links.LinksService/Delete"
+	// LinksServiceListProcedure is the fully-qualified name of the LinksService's List RPC.
+	LinksServiceListProcedure = "/links.LinksService/List"
+	// LinksServiceBulkPutProcedure is the fully-qualified name of the LinksService's BulkPut RPC.
+	LinksServiceBulkPutProcedure = "/links.LinksService/BulkPut"
+)
+
+// LinksServiceClient is a client for the links.LinksService service.
+type LinksServiceClient interface {
+	// Get returns a single link, or fails with NotFound.
+	Get(context.Context, *connect.Request[links.GetRequest]) (*connect.Response[links.Link], error)
+	// Put creates or updates a link.
+	Put(context.Context, *connect.Request[links.PutRequest]) (*connect.Response[links.PutResponse], error)
+	// Delete removes a link, failing with NotFound if there is none unless
+	// missing_ok is set.
+	Delete(context.Context, *connect.Request[links.DeleteRequest]) (*connect.Response[links.DeleteResponse], error)
+	// List returns every link.
+	List(context.Context, *connect.Request[links.ListRequest]) (*connect.Response[links.Links], error)
+	// BulkPut imports many links atomically, like POST /api/links.
+	BulkPut(context.Context, *connect.Request[links.BulkPutRequest]) (*connect.Response[links.BulkPutResponse], error)
+}
+
+// NewLinksServiceClient constructs a client for the links.LinksService service. By default, it uses
+// the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewLinksServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) LinksServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	linksServiceMethods := links.File_proto_links_links_proto.Services().ByName("LinksService").Methods()
+	return &linksServiceClient{
+		get: connect.NewClient[links.GetRequest, links.Link](
+			httpClient,
+			baseURL+LinksServiceGetProcedure,
+			connect.WithSchema(linksServiceMethods.ByName("Get")),
+			connect.WithClientOptions(opts...),
+		),
+		put: connect.NewClient[links.PutRequest, links.PutResponse](
+			httpClient,
+			baseURL+LinksServicePutProcedure,
+			connect.WithSchema(linksServiceMethods.ByName("Put")),
+			connect.WithClientOptions(opts...),
+		),
+		delete: connect.NewClient[links.DeleteRequest, links.DeleteResponse](
+			httpClient,
+			baseURL+LinksServiceDeleteProcedure,
+			connect.WithSchema(linksServiceMethods.ByName("Delete")),
+			connect.WithClientOptions(opts...),
+		),
+		list: connect.NewClient[links.ListRequest, links.Links](
+			httpClient,
+			baseURL+LinksServiceListProcedure,
+			connect.WithSchema(linksServiceMethods.ByName("List")),
+			connect.WithClientOptions(opts...),
+		),
+		bulkPut: connect.NewClient[links.BulkPutRequest, links.BulkPutResponse](
+			httpClient,
+			baseURL+LinksServiceBulkPutProcedure,
+			connect.WithSchema(linksServiceMethods.ByName("BulkPut")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// linksServiceClient implements LinksServiceClient.
+type linksServiceClient struct {
+	get     *connect.Client[links.GetRequest, links.Link]
+	put     *connect.Client[links.PutRequest, links.PutResponse]
+	delete  *connect.Client[links.DeleteRequest, links.DeleteResponse]
+	list    *connect.Client[links.ListRequest, links.Links]
+	bulkPut *connect.Client[links.BulkPutRequest, links.BulkPutResponse]
+}
+
+// Get calls links.LinksService.Get.
+func (c *linksServiceClient) Get(ctx context.Context, req *connect.Request[links.GetRequest]) (*connect.Response[links.Link], error) {
+	return c.get.CallUnary(ctx, req)
+}
+
+// Put calls links.LinksService.Put.
+func (c *linksServiceClient) Put(ctx context.Context, req *connect.Request[links.PutRequest]) (*connect.Response[links.PutResponse], error) {
+	return c.put.CallUnary(ctx, req)
+}
+
+// Delete calls links.LinksService.Delete.
+func (c *linksServiceClient) Delete(ctx context.Context, req *connect.Request[links.DeleteRequest]) (*connect.Response[links.DeleteResponse], error) {
+	return c.delete.CallUnary(ctx, req)
+}
+
+// List calls links.LinksService.List.
+func (c *linksServiceClient) List(ctx context.Context, req *connect.Request[links.ListRequest]) (*connect.Response[links.Links], error) {
+	return c.list.CallUnary(ctx, req)
+}
+
+// BulkPut calls links.LinksService.BulkPut.
+func (c *linksServiceClient) BulkPut(ctx context.Context, req *connect.Request[links.BulkPutRequest]) (*connect.Response[links.BulkPutResponse], error) {
+	return c.bulkPut.CallUnary(ctx, req)
+}
+
+// LinksServiceHandler is an implementation of the links.LinksService service.
+type LinksServiceHandler interface {
+	// Get returns a single link, or fails with NotFound.
+	Get(context.Context, *connect.Request[links.GetRequest]) (*connect.Response[links.Link], error)
+	// Put creates or updates a link.
+	Put(context.Context, *connect.Request[links.PutRequest]) (*connect.Response[links.PutResponse], error)
+	// Delete removes a link, failing with NotFound if there is none unless
+	// missing_ok is set.
+	Delete(context.Context, *connect.Request[links.DeleteRequest]) (*connect.Response[links.DeleteResponse], error)
+	// List returns every link.
+	List(context.Context, *connect.Request[links.ListRequest]) (*connect.Response[links.Links], error)
+	// BulkPut imports many links atomically, like POST /api/links.
+	BulkPut(context.Context, *connect.Request[links.BulkPutRequest]) (*connect.Response[links.BulkPutResponse], error)
+}
+
+// NewLinksServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewLinksServiceHandler(svc LinksServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	linksServiceMethods := links.File_proto_links_links_proto.Services().ByName("LinksService").Methods()
+	linksServiceGetHandler := connect.NewUnaryHandler(
+		LinksServiceGetProcedure,
+		svc.Get,
+		connect.WithSchema(linksServiceMethods.ByName("Get")),
+		connect.WithHandlerOptions(opts...),
+	)
+	linksServicePutHandler := connect.NewUnaryHandler(
+		LinksServicePutProcedure,
+		svc.Put,
+		connect.WithSchema(linksServiceMethods.ByName("Put")),
+		connect.WithHandlerOptions(opts...),
+	)
+	linksServiceDeleteHandler := connect.NewUnaryHandler(
+		LinksServiceDeleteProcedure,
+		svc.Delete,
+		connect.WithSchema(linksServiceMethods.ByName("Delete")),
+		connect.WithHandlerOptions(opts...),
+	)
+	linksServiceListHandler := connect.NewUnaryHandler(
+		LinksServiceListProcedure,
+		svc.List,
+		connect.WithSchema(linksServiceMethods.ByName("List")),
+		connect.WithHandlerOptions(opts...),
+	)
+	linksServiceBulkPutHandler := connect.NewUnaryHandler(
+		LinksServiceBulkPutProcedure,
+		svc.BulkPut,
+		connect.WithSchema(linksServiceMethods.ByName("BulkPut")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/links.LinksService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case LinksServiceGetProcedure:
+			linksServiceGetHandler.ServeHTTP(w, r)
+		case LinksServicePutProcedure:
+			linksServicePutHandler.ServeHTTP(w, r)
+		case LinksServiceDeleteProcedure:
+			linksServiceDeleteHandler.ServeHTTP(w, r)
+		case LinksServiceListProcedure:
+			linksServiceListHandler.ServeHTTP(w, r)
+		case LinksServiceBulkPutProcedure:
+			linksServiceBulkPutHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedLinksServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedLinksServiceHandler struct{}
+
+func (UnimplementedLinksServiceHandler) Get(context.Context, *connect.Request[links.GetRequest]) (*connect.Response[links.Link], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("links.LinksService.Get is not implemented"))
+}
+
+func (UnimplementedLinksServiceHandler) Put(context.Context, *connect.Request[links.PutRequest]) (*connect.Response[links.PutResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("links.LinksService.Put is not implemented"))
+}
+
+func (UnimplementedLinksServiceHandler) Delete(context.Context, *connect.Request[links.DeleteRequest]) (*connect.Response[links.DeleteResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("links.LinksService.Delete is not implemented"))
+}
+
+func (UnimplementedLinksServiceHandler) List(context.Context, *connect.Request[links.ListRequest]) (*connect.Response[links.Links], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("links.LinksService.List is not implemented"))
+}
+
+func (UnimplementedLinksServiceHandler) BulkPut(context.Context, *connect.Request[links.BulkPutRequest]) (*connect.Response[links.BulkPutResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("links.LinksService.BulkPut is not implemented"))
+}
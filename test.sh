@@ -32,7 +32,7 @@ export LINKS_KEYSET=$(base64 -i "${KEYSET}")
 
 "${TEST_DIR}/links" &
 
-until curl -s "${ADDR}"; do
+until curl -sf "${ADDR}/readyz"; do
     echo "Waiting for server to start..."
     sleep 1
 done
@@ -87,9 +87,11 @@ result=$(curl -s "${ADDR}/foo" -o /dev/null -w "%{http_code}")
 test "${result}" = "404"
 
 echo "Test deleting something already deleted..."
-"${TEST_DIR}/client" --priv "${PRIV}" \
-         --addr "${ADDR}" \
-         --rm "foo"
+result=$("${TEST_DIR}/client" --priv "${PRIV}" \
+                  --addr "${ADDR}" \
+                  --rm "foo" &&\
+             echo "succeeded" || echo "failed")
+test "${result}" = "failed"
 
 echo "Testing failed authorization..."
 "${TEST_DIR}/tokenpb" gen-key --subject "untrusted" --pub "${TEST_DIR}/untrustedpub.pb" --priv "${TEST_DIR}/untrustedpriv.pb"